@@ -0,0 +1,58 @@
+package bitbucket
+
+import (
+	"context"
+	"fmt"
+
+	"autopuller/env"
+	"autopuller/source"
+)
+
+// CheckAccess confirms the followed repository can be read with the
+// configured credentials and describes it.
+func (b *RealBitbucketCloudAPI) CheckAccess(ctx context.Context) (string, error) {
+	if b.RepoName() == "" {
+		return "", fmt.Errorf("repo (REPONAME) is not set")
+	}
+
+	var repo struct {
+		IsPrivate bool `json:"is_private"`
+	}
+	if err := getJSON(ctx, b.cloudURL(""), &repo); err != nil {
+		return "", source.AccessError(b.RepoName(), err)
+	}
+	return describeAccess(b.RepoName(), repo.IsPrivate), nil
+}
+
+// CheckAccess confirms the followed repository can be read with the
+// configured credentials and describes it.
+func (b *RealBitbucketServerAPI) CheckAccess(ctx context.Context) (string, error) {
+	if b.RepoName() == "" {
+		return "", fmt.Errorf("repo (REPONAME) is not set")
+	}
+
+	var repo struct {
+		Public bool `json:"public"`
+	}
+	if err := getJSON(ctx, b.serverURL(""), &repo); err != nil {
+		return "", source.AccessError(b.RepoName(), err)
+	}
+	return describeAccess(b.RepoName(), !repo.Public), nil
+}
+
+// describeAccess describes a readable repository and the credentials, if
+// any, used to read it.
+func describeAccess(repo string, private bool) string {
+	visibility := "public"
+	if private {
+		visibility = "private"
+	}
+	cfg := env.Current().Bitbucket
+	switch {
+	case cfg.Key == "":
+		return fmt.Sprintf("%s is %s and readable without credentials", repo, visibility)
+	case cfg.User != "":
+		return fmt.Sprintf("%s is %s and readable with the app password of %s", repo, visibility, cfg.User)
+	}
+	return fmt.Sprintf("%s is %s and readable with the access token", repo, visibility)
+}
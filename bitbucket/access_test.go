@@ -0,0 +1,52 @@
+package bitbucket
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestCheckAccess checks that readable Bitbucket Cloud and Server
+// repositories are described and that a hidden one is explained.
+func TestCheckAccess(t *testing.T) {
+	ts := newFakeBitbucket(t, map[string]string{
+		"/2.0/repositories/workspace/repo":       `{"is_private": true}`,
+		"/rest/api/1.0/projects/PROJ/repos/repo": `{"public": true}`,
+	})
+	defer ts.Close()
+
+	os.Setenv("REPONAME", "workspace/repo")
+	desc, err := (&RealBitbucketCloudAPI{}).CheckAccess(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if desc != "workspace/repo is private and readable with the access token" {
+		t.Fatalf("Expected the repository to be described, got '%s'", desc)
+	}
+
+	os.Setenv("REPONAME", "PROJ/repo")
+	desc, err = (&RealBitbucketServerAPI{}).CheckAccess(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if desc != "PROJ/repo is public and readable with the access token" {
+		t.Fatalf("Expected the repository to be described, got '%s'", desc)
+	}
+
+	os.Setenv("REPONAME", "PROJ/other")
+	if _, err := (&RealBitbucketServerAPI{}).CheckAccess(context.Background()); err == nil || !strings.Contains(err.Error(), "PROJ/other not found or not visible") {
+		t.Fatalf("Expected a hidden repository to be explained, got %v", err)
+	}
+}
+
+// TestDescribeAccess checks that an app password is named by its user.
+func TestDescribeAccess(t *testing.T) {
+	os.Setenv("BITBUCKETKEY", "fake-key")
+	os.Setenv("BITBUCKET_USER", "deployer")
+	defer os.Unsetenv("BITBUCKET_USER")
+
+	if desc := describeAccess("workspace/repo", false); desc != "workspace/repo is public and readable with the app password of deployer" {
+		t.Fatalf("Expected the app password to be described, got '%s'", desc)
+	}
+}
@@ -0,0 +1,109 @@
+package bitbucket
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"autopuller/env"
+	"autopuller/source"
+)
+
+// authHeader authenticates with an app password when BITBUCKET_USER is set,
+// and with BITBUCKETKEY as an access token otherwise.
+func authHeader() http.Header {
+	header := http.Header{}
+	cfg := env.Current().Bitbucket
+	key := cfg.Key
+	if key == "" {
+		return header
+	}
+	if user := cfg.User; user != "" {
+		header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(user+":"+key)))
+		return header
+	}
+	header.Set("Authorization", "Bearer "+key)
+	return header
+}
+
+// getJSON performs an authenticated GET request and decodes the JSON response into result.
+func getJSON(ctx context.Context, url string, result interface{}) error {
+	_, err := source.GetJSON(ctx, url, authHeader(), result)
+	return err
+}
+
+// getCloudPages walks a Bitbucket Cloud paginated response, which links the
+// next page in its body, calling each with the values of every page.
+func getCloudPages(ctx context.Context, url string, each func(values json.RawMessage) error) error {
+	for url != "" {
+		var page struct {
+			Values json.RawMessage `json:"values"`
+			Next   string          `json:"next"`
+		}
+		if err := getJSON(ctx, url, &page); err != nil {
+			return err
+		}
+		if err := each(page.Values); err != nil {
+			return err
+		}
+		url = page.Next
+	}
+	return nil
+}
+
+// getServerPages walks a Bitbucket Server paginated response, which is paged
+// with a start offset, calling each with the values of every page.
+func getServerPages(ctx context.Context, url string, each func(values json.RawMessage) error) error {
+	separator := "?"
+	if strings.Contains(url, "?") {
+		separator = "&"
+	}
+	start := 0
+	for {
+		var page struct {
+			Values        json.RawMessage `json:"values"`
+			IsLastPage    bool            `json:"isLastPage"`
+			NextPageStart int             `json:"nextPageStart"`
+		}
+		if err := getJSON(ctx, fmt.Sprintf("%s%sstart=%d", url, separator, start), &page); err != nil {
+			return err
+		}
+		if err := each(page.Values); err != nil {
+			return err
+		}
+		if page.IsLastPage || page.NextPageStart <= start {
+			return nil
+		}
+		start = page.NextPageStart
+	}
+}
+
+// buildState maps a Bitbucket build status state to a CIState.
+func buildState(state string) source.CIState {
+	switch state {
+	case "SUCCESSFUL":
+		return source.CIPassed
+	case "INPROGRESS":
+		return source.CIPending
+	}
+	return source.CIFailed
+}
+
+// buildStatus is a build status reported for a commit.
+type buildStatus struct {
+	Key   string `json:"key"`
+	Name  string `json:"name"`
+	State string `json:"state"`
+}
+
+// checkResult converts the build status, naming it by name or else key.
+func (b buildStatus) checkResult() source.CheckResult {
+	name := b.Name
+	if name == "" {
+		name = b.Key
+	}
+	return source.CheckResult{Name: name, State: buildState(b.State)}
+}
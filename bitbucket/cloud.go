@@ -0,0 +1,130 @@
+package bitbucket
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+
+	"autopuller/env"
+	"autopuller/source"
+)
+
+// RealBitbucketCloudAPI is the Bitbucket Cloud implementation of source.Source.
+type RealBitbucketCloudAPI struct {
+	source.GitCheckout
+}
+
+// cloudURL builds an API URL for a path under the followed repository,
+// named workspace/repo_slug.
+func (b *RealBitbucketCloudAPI) cloudURL(path string) string {
+	baseURL := env.Current().Bitbucket.URL
+	if baseURL == "" {
+		baseURL = "https://api.bitbucket.org"
+	}
+	return baseURL + "/2.0/repositories/" + b.RepoName() + path
+}
+
+// GetBranchSum fetches the latest commit SHA from Bitbucket for the given branch.
+func (b *RealBitbucketCloudAPI) GetBranchSum(ctx context.Context, branch string) (string, error) {
+	var result struct {
+		Target struct {
+			Hash string `json:"hash"`
+		} `json:"target"`
+	}
+	if err := getJSON(ctx, b.cloudURL("/refs/branches/"+url.PathEscape(branch)), &result); err != nil {
+		return "", err
+	}
+	return result.Target.Hash, nil
+}
+
+// CheckLastRun gathers the build statuses of the commit.
+func (b *RealBitbucketCloudAPI) CheckLastRun(ctx context.Context, sha string, workflows []string) ([]source.CheckResult, []source.CheckResult, error) {
+	var checks []source.CheckResult
+	err := getCloudPages(ctx, b.cloudURL("/commit/"+sha+"/statuses?pagelen=100"), func(values json.RawMessage) error {
+		var statuses []buildStatus
+		if err := json.Unmarshal(values, &statuses); err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			checks = append(checks, status.checkResult())
+		}
+		return nil
+	})
+	return checks, nil, err
+}
+
+// CheckDifferences compares two SHAs and returns a list of changed files
+// from the diffstat. Bitbucket specs read newer..older.
+func (b *RealBitbucketCloudAPI) CheckDifferences(ctx context.Context, oldSha, newSha string) ([]string, error) {
+	var diffs []string
+	err := getCloudPages(ctx, b.cloudURL("/diffstat/"+newSha+".."+oldSha+"?pagelen=500"), func(values json.RawMessage) error {
+		var page []struct {
+			Status string `json:"status"`
+			Old    *struct {
+				Path string `json:"path"`
+			} `json:"old"`
+			New *struct {
+				Path string `json:"path"`
+			} `json:"new"`
+		}
+		if err := json.Unmarshal(values, &page); err != nil {
+			return err
+		}
+		for _, diff := range page {
+			if diff.New != nil {
+				diffs = append(diffs, diff.New.Path)
+			} else if diff.Old != nil {
+				diffs = append(diffs, diff.Old.Path)
+			}
+		}
+		return nil
+	})
+	return diffs, err
+}
+
+// ListCommits lists the SHAs of the commits after oldSha up to and including
+// newSha on the first-parent line of newSha, oldest first.
+func (b *RealBitbucketCloudAPI) ListCommits(ctx context.Context, oldSha, newSha string) ([]string, error) {
+	parents := map[string][]string{}
+	err := getCloudPages(ctx, b.cloudURL("/commits/"+newSha+"?exclude="+oldSha+"&pagelen=100"), func(values json.RawMessage) error {
+		var page []struct {
+			Hash    string `json:"hash"`
+			Parents []struct {
+				Hash string `json:"hash"`
+			} `json:"parents"`
+		}
+		if err := json.Unmarshal(values, &page); err != nil {
+			return err
+		}
+		for _, commit := range page {
+			parents[commit.Hash] = nil
+			for _, parent := range commit.Parents {
+				parents[commit.Hash] = append(parents[commit.Hash], parent.Hash)
+			}
+		}
+		return nil
+	})
+	return source.FirstParentLine(newSha, parents), err
+}
+
+// ListReleases lists the tags of the repository. Bitbucket has no releases,
+// so both release modes consider tags.
+func (b *RealBitbucketCloudAPI) ListReleases(ctx context.Context) ([]source.ReleaseCandidate, error) {
+	var candidates []source.ReleaseCandidate
+	err := getCloudPages(ctx, b.cloudURL("/refs/tags?pagelen=100"), func(values json.RawMessage) error {
+		var page []struct {
+			Name   string `json:"name"`
+			Target struct {
+				Hash string `json:"hash"`
+			} `json:"target"`
+		}
+		if err := json.Unmarshal(values, &page); err != nil {
+			return err
+		}
+		for _, tag := range page {
+			candidates = append(candidates, source.ReleaseCandidate{Tag: tag.Name, Sha: tag.Target.Hash})
+		}
+		return nil
+	})
+	return candidates, err
+}
@@ -0,0 +1,142 @@
+package bitbucket
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"autopuller/source"
+)
+
+// newFakeBitbucket starts an httptest stand-in for the Bitbucket API serving
+// the given responses by path plus a page or start query, if present, and
+// answering 404 to anything else. {{URL}} in a response is replaced by the
+// server URL so pages can link to each other. The backends are pointed at it.
+func newFakeBitbucket(t *testing.T, responses map[string]string) *httptest.Server {
+	t.Helper()
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer fake-key" {
+			t.Errorf("Expected the Authorization header to be set")
+		}
+		key := r.URL.Path
+		if page := r.URL.Query().Get("page"); page != "" {
+			key += "?page=" + page
+		}
+		if start := r.URL.Query().Get("start"); start != "" && start != "0" {
+			key += "?start=" + start
+		}
+		body, ok := responses[key]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(strings.Replace(body, "{{URL}}", ts.URL, -1)))
+	}))
+
+	os.Setenv("BITBUCKET_URL", ts.URL)
+	os.Setenv("BITBUCKETKEY", "fake-key")
+	return ts
+}
+
+func TestCloudGetBranchSum(t *testing.T) {
+	ts := newFakeBitbucket(t, map[string]string{
+		"/2.0/repositories/workspace/repo/refs/branches/main": `{"name": "main", "target": {"hash": "fake-sha"}}`,
+	})
+	defer ts.Close()
+	os.Setenv("REPONAME", "workspace/repo")
+
+	bitbucket := &RealBitbucketCloudAPI{}
+	sha, err := bitbucket.GetBranchSum(context.Background(), "main")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if sha != "fake-sha" {
+		t.Fatalf("Expected SHA 'fake-sha', got '%s'", sha)
+	}
+
+	if _, err := bitbucket.GetBranchSum(context.Background(), "missing"); err == nil {
+		t.Fatalf("Expected an error for a missing branch, but got nil")
+	}
+}
+
+func TestCloudCheckLastRun(t *testing.T) {
+	ts := newFakeBitbucket(t, map[string]string{
+		"/2.0/repositories/workspace/repo/commit/fake-sha/statuses": `{
+			"values": [{"key": "build-1", "name": "Pipeline #1", "state": "SUCCESSFUL"}],
+			"next": "{{URL}}/2.0/repositories/workspace/repo/commit/fake-sha/statuses?page=2"
+		}`,
+		"/2.0/repositories/workspace/repo/commit/fake-sha/statuses?page=2": `{
+			"values": [{"key": "deploy-check", "state": "INPROGRESS"}]
+		}`,
+	})
+	defer ts.Close()
+	os.Setenv("REPONAME", "workspace/repo")
+
+	bitbucket := &RealBitbucketCloudAPI{}
+	checks, _, err := bitbucket.CheckLastRun(context.Background(), "fake-sha", nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(checks) != 2 || checks[0].State != source.CIPassed || checks[1].State != source.CIPending {
+		t.Fatalf("Expected a passed and a pending check, got %v", checks)
+	}
+}
+
+func TestCloudCheckDifferences(t *testing.T) {
+	ts := newFakeBitbucket(t, map[string]string{
+		"/2.0/repositories/workspace/repo/diffstat/newSha..oldSha": `{"values": [
+			{"status": "modified", "old": {"path": "file1.txt"}, "new": {"path": "file1.txt"}},
+			{"status": "added", "old": null, "new": {"path": "file2.txt"}},
+			{"status": "removed", "old": {"path": "gone.txt"}, "new": null}
+		]}`,
+		"/2.0/repositories/workspace/repo/commits/newSha": `{"values": [
+			{"hash": "newSha", "parents": [{"hash": "sha1"}, {"hash": "side"}]},
+			{"hash": "side", "parents": [{"hash": "oldSha"}]},
+			{"hash": "sha1", "parents": [{"hash": "oldSha"}]}
+		]}`,
+	})
+	defer ts.Close()
+	os.Setenv("REPONAME", "workspace/repo")
+
+	bitbucket := &RealBitbucketCloudAPI{}
+	diffs, err := bitbucket.CheckDifferences(context.Background(), "oldSha", "newSha")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(diffs) != 3 || diffs[0] != "file1.txt" || diffs[1] != "file2.txt" || diffs[2] != "gone.txt" {
+		t.Fatalf("Expected [file1.txt file2.txt gone.txt], got %v", diffs)
+	}
+
+	commits, err := bitbucket.ListCommits(context.Background(), "oldSha", "newSha")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(commits) != 2 || commits[0] != "sha1" || commits[1] != "newSha" {
+		t.Fatalf("Expected [sha1 newSha], got %v", commits)
+	}
+}
+
+func TestCloudLatestRelease(t *testing.T) {
+	ts := newFakeBitbucket(t, map[string]string{
+		"/2.0/repositories/workspace/repo/refs/tags": `{"values": [
+			{"name": "v1.0.0", "target": {"hash": "sha-100"}},
+			{"name": "v1.1.0-beta", "target": {"hash": "sha-110b"}}
+		]}`,
+	})
+	defer ts.Close()
+	os.Setenv("REPONAME", "workspace/repo")
+
+	bitbucket := &RealBitbucketCloudAPI{}
+	release, err := source.LatestRelease(context.Background(), bitbucket, "^1", false)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if release.Tag != "v1.0.0" || release.Sha != "sha-100" {
+		t.Fatalf("Expected v1.0.0 (sha-100), got %s (%s)", release.Tag, release.Sha)
+	}
+}
@@ -0,0 +1,130 @@
+package bitbucket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"autopuller/env"
+	"autopuller/source"
+)
+
+// RealBitbucketServerAPI is the Bitbucket Server (Data Center) implementation
+// of source.Source.
+type RealBitbucketServerAPI struct {
+	source.GitCheckout
+}
+
+// serverURL builds an API URL for a path under the followed repository.
+// The repository name is PROJECT/repo and BITBUCKET_URL the instance.
+func (b *RealBitbucketServerAPI) serverURL(path string) string {
+	parts := strings.SplitN(b.RepoName(), "/", 2)
+	project, repo := parts[0], ""
+	if len(parts) == 2 {
+		repo = parts[1]
+	}
+	return fmt.Sprintf("%s/rest/api/1.0/projects/%s/repos/%s%s", strings.TrimSuffix(env.Current().Bitbucket.URL, "/"), project, repo, path)
+}
+
+// GetBranchSum fetches the latest commit SHA from Bitbucket for the given branch.
+func (b *RealBitbucketServerAPI) GetBranchSum(ctx context.Context, branch string) (string, error) {
+	var result struct {
+		Values []struct {
+			ID string `json:"id"`
+		} `json:"values"`
+	}
+	query := url.Values{"until": {"refs/heads/" + branch}, "limit": {"1"}}
+	if err := getJSON(ctx, b.serverURL("/commits?"+query.Encode()), &result); err != nil {
+		return "", err
+	}
+	if len(result.Values) == 0 {
+		return "", fmt.Errorf("branch %s has no commits", branch)
+	}
+	return result.Values[0].ID, nil
+}
+
+// CheckLastRun gathers the build statuses of the commit.
+func (b *RealBitbucketServerAPI) CheckLastRun(ctx context.Context, sha string, workflows []string) ([]source.CheckResult, []source.CheckResult, error) {
+	var checks []source.CheckResult
+	statusURL := strings.TrimSuffix(env.Current().Bitbucket.URL, "/") + "/rest/build-status/1.0/commits/" + sha + "?limit=100"
+	err := getServerPages(ctx, statusURL, func(values json.RawMessage) error {
+		var statuses []buildStatus
+		if err := json.Unmarshal(values, &statuses); err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			checks = append(checks, status.checkResult())
+		}
+		return nil
+	})
+	return checks, nil, err
+}
+
+// CheckDifferences compares two SHAs and returns a list of changed files.
+func (b *RealBitbucketServerAPI) CheckDifferences(ctx context.Context, oldSha, newSha string) ([]string, error) {
+	var diffs []string
+	query := url.Values{"from": {newSha}, "to": {oldSha}, "limit": {"500"}}
+	err := getServerPages(ctx, b.serverURL("/compare/changes?"+query.Encode()), func(values json.RawMessage) error {
+		var page []struct {
+			Path struct {
+				ToString string `json:"toString"`
+			} `json:"path"`
+		}
+		if err := json.Unmarshal(values, &page); err != nil {
+			return err
+		}
+		for _, change := range page {
+			diffs = append(diffs, change.Path.ToString)
+		}
+		return nil
+	})
+	return diffs, err
+}
+
+// ListCommits lists the SHAs of the commits after oldSha up to and including
+// newSha on the first-parent line of newSha, oldest first.
+func (b *RealBitbucketServerAPI) ListCommits(ctx context.Context, oldSha, newSha string) ([]string, error) {
+	parents := map[string][]string{}
+	query := url.Values{"since": {oldSha}, "until": {newSha}, "limit": {"100"}}
+	err := getServerPages(ctx, b.serverURL("/commits?"+query.Encode()), func(values json.RawMessage) error {
+		var page []struct {
+			ID      string `json:"id"`
+			Parents []struct {
+				ID string `json:"id"`
+			} `json:"parents"`
+		}
+		if err := json.Unmarshal(values, &page); err != nil {
+			return err
+		}
+		for _, commit := range page {
+			parents[commit.ID] = nil
+			for _, parent := range commit.Parents {
+				parents[commit.ID] = append(parents[commit.ID], parent.ID)
+			}
+		}
+		return nil
+	})
+	return source.FirstParentLine(newSha, parents), err
+}
+
+// ListReleases lists the tags of the repository. Bitbucket has no releases,
+// so both release modes consider tags.
+func (b *RealBitbucketServerAPI) ListReleases(ctx context.Context) ([]source.ReleaseCandidate, error) {
+	var candidates []source.ReleaseCandidate
+	err := getServerPages(ctx, b.serverURL("/tags?limit=100"), func(values json.RawMessage) error {
+		var page []struct {
+			DisplayID    string `json:"displayId"`
+			LatestCommit string `json:"latestCommit"`
+		}
+		if err := json.Unmarshal(values, &page); err != nil {
+			return err
+		}
+		for _, tag := range page {
+			candidates = append(candidates, source.ReleaseCandidate{Tag: tag.DisplayID, Sha: tag.LatestCommit})
+		}
+		return nil
+	})
+	return candidates, err
+}
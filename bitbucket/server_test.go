@@ -0,0 +1,106 @@
+package bitbucket
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"autopuller/source"
+)
+
+func TestServerGetBranchSum(t *testing.T) {
+	ts := newFakeBitbucket(t, map[string]string{
+		"/rest/api/1.0/projects/PROJ/repos/repo/commits": `{"values": [{"id": "fake-sha"}], "isLastPage": true}`,
+	})
+	defer ts.Close()
+	os.Setenv("REPONAME", "PROJ/repo")
+
+	bitbucket := &RealBitbucketServerAPI{}
+	sha, err := bitbucket.GetBranchSum(context.Background(), "main")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if sha != "fake-sha" {
+		t.Fatalf("Expected SHA 'fake-sha', got '%s'", sha)
+	}
+}
+
+func TestServerCheckLastRun(t *testing.T) {
+	ts := newFakeBitbucket(t, map[string]string{
+		"/rest/build-status/1.0/commits/fake-sha": `{
+			"values": [{"key": "unit", "name": "Unit tests", "state": "SUCCESSFUL"}],
+			"isLastPage": false, "nextPageStart": 1
+		}`,
+		"/rest/build-status/1.0/commits/fake-sha?start=1": `{
+			"values": [{"key": "e2e", "name": "E2E tests", "state": "FAILED"}],
+			"isLastPage": true
+		}`,
+	})
+	defer ts.Close()
+	os.Setenv("REPONAME", "PROJ/repo")
+
+	bitbucket := &RealBitbucketServerAPI{}
+	checks, _, err := bitbucket.CheckLastRun(context.Background(), "fake-sha", nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(checks) != 2 || checks[0].State != source.CIPassed || checks[1].State != source.CIFailed {
+		t.Fatalf("Expected a passed and a failed check, got %v", checks)
+	}
+}
+
+func TestServerCheckDifferences(t *testing.T) {
+	ts := newFakeBitbucket(t, map[string]string{
+		"/rest/api/1.0/projects/PROJ/repos/repo/compare/changes": `{
+			"values": [{"path": {"toString": "src/main.go"}}, {"path": {"toString": "README.md"}}],
+			"isLastPage": true
+		}`,
+		"/rest/api/1.0/projects/PROJ/repos/repo/commits": `{
+			"values": [
+				{"id": "newSha", "parents": [{"id": "sha1"}, {"id": "side"}]},
+				{"id": "side", "parents": [{"id": "oldSha"}]},
+				{"id": "sha1", "parents": [{"id": "oldSha"}]}
+			],
+			"isLastPage": true
+		}`,
+	})
+	defer ts.Close()
+	os.Setenv("REPONAME", "PROJ/repo")
+
+	bitbucket := &RealBitbucketServerAPI{}
+	diffs, err := bitbucket.CheckDifferences(context.Background(), "oldSha", "newSha")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(diffs) != 2 || diffs[0] != "src/main.go" || diffs[1] != "README.md" {
+		t.Fatalf("Expected [src/main.go README.md], got %v", diffs)
+	}
+
+	commits, err := bitbucket.ListCommits(context.Background(), "oldSha", "newSha")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(commits) != 2 || commits[0] != "sha1" || commits[1] != "newSha" {
+		t.Fatalf("Expected [sha1 newSha], got %v", commits)
+	}
+}
+
+func TestServerLatestRelease(t *testing.T) {
+	ts := newFakeBitbucket(t, map[string]string{
+		"/rest/api/1.0/projects/PROJ/repos/repo/tags": `{
+			"values": [{"displayId": "v3.2.1", "latestCommit": "sha-321"}, {"displayId": "v3.10.0", "latestCommit": "sha-3100"}],
+			"isLastPage": true
+		}`,
+	})
+	defer ts.Close()
+	os.Setenv("REPONAME", "PROJ/repo")
+
+	bitbucket := &RealBitbucketServerAPI{}
+	release, err := source.LatestRelease(context.Background(), bitbucket, "", false)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if release.Tag != "v3.10.0" || release.Sha != "sha-3100" {
+		t.Fatalf("Expected v3.10.0 (sha-3100), got %s (%s)", release.Tag, release.Sha)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"autopuller/docker"
+	"autopuller/env"
+	"autopuller/source"
+)
+
+// accessChecker is implemented by sources that can verify their credentials
+// against the followed repository, as every backend does.
+type accessChecker interface {
+	CheckAccess(ctx context.Context) (string, error)
+}
+
+// composeChecker is implemented by Docker managers that can verify the
+// compose command works for the project.
+type composeChecker interface {
+	CheckCompose(ctx context.Context) ([]string, error)
+}
+
+// checkCheckout verifies the local checkout, overridable in tests.
+var checkCheckout = source.CheckCheckout
+
+// checkReport prints one PASS or FAIL line per check and remembers whether
+// any check failed.
+type checkReport struct {
+	out      io.Writer
+	failures int
+}
+
+// add reports the outcome of a check, described by detail when it passed.
+func (r *checkReport) add(name, detail string, err error) {
+	if err != nil {
+		r.failures++
+		fmt.Fprintf(r.out, "FAIL  %s: %v\n", name, err)
+		return
+	}
+	fmt.Fprintf(r.out, "PASS  %s: %s\n", name, detail)
+}
+
+// skip reports a check that couldn't be run, without failing the report.
+func (r *checkReport) skip(name, reason string) {
+	fmt.Fprintf(r.out, "SKIP  %s: %s\n", name, reason)
+}
+
+// finish prints the summary line and returns whether every check passed.
+func (r *checkReport) finish() bool {
+	if r.failures > 0 {
+		fmt.Fprintf(r.out, "%d check(s) failed.\n", r.failures)
+		return false
+	}
+	fmt.Fprintln(r.out, "All checks passed.")
+	return true
+}
+
+// runCheck validates the configuration and, for each project, the access to
+// its repository, its checkout and its compose project, printing a report to
+// out. It returns whether every check passed.
+func runCheck(ctx context.Context, out io.Writer) bool {
+	report := &checkReport{out: out}
+
+	// The .env file is optional when a config file is given, as when starting up
+	if err := env.LoadEnv(); err != nil && env.GetConfigFile() == "" {
+		report.add("env", "", err)
+		return report.finish()
+	}
+
+	if _, err := env.LoadConfig(); err != nil {
+		report.add("config", "", err)
+		return report.finish()
+	}
+	configFile := env.GetConfigFile()
+	if configFile == "" {
+		configFile = "the environment"
+	}
+	report.add("config", "loaded from "+configFile, nil)
+
+	if bundle := env.GetCABundle(); bundle != "" {
+		report.add("ca bundle", "loaded "+bundle, source.UseCABundle(bundle))
+	}
+
+	projects, err := env.LoadProjects()
+	if err != nil {
+		report.add("projects", "", err)
+		return report.finish()
+	}
+	for _, project := range projects {
+		src, err := newSource(project)
+		if err != nil {
+			report.add(project.LogPrefix()+"source", "", err)
+			continue
+		}
+		checkProject(ctx, report, project, src, &docker.RealDockerManager{Dir: project.DockerDir})
+	}
+	return report.finish()
+}
+
+// checkProject checks that the project's repository can be read, that its
+// checkout is clean and on the tracked branch, and that its compose command
+// works.
+func checkProject(ctx context.Context, report *checkReport, project env.Project, src source.Source, dockerMgr docker.DockerManager) {
+	name := project.LogPrefix()
+	ctx = source.WithLogger(ctx, log.New(log.Writer(), name, log.Flags()|log.Lmsgprefix))
+
+	if checker, ok := src.(accessChecker); ok {
+		desc, err := checker.CheckAccess(ctx)
+		report.add(name+"access", desc, err)
+	} else {
+		report.skip(name+"access", "not supported for SOURCE="+env.GetSource())
+	}
+
+	// Resolve what would be deployed, which needs the same access as polling
+	branch := project.GetBranch()
+	if env.GetReleaseMode() != "" {
+		branch = ""
+		release, err := source.LatestRelease(ctx, src, env.GetReleaseConstraint(), env.GetReleasePrerelease())
+		report.add(name+"release", fmt.Sprintf("newest release is %s (%s)", release.Tag, release.Sha), err)
+	} else {
+		sha, err := src.GetBranchSum(ctx, branch)
+		report.add(name+"branch", fmt.Sprintf("%s is at %s", branch, sha), err)
+	}
+
+	desc, err := checkCheckout(ctx, project.GetRepoDir(), branch)
+	report.add(name+"checkout", fmt.Sprintf("%s is on %s", project.GetRepoDir(), desc), err)
+
+	if checker, ok := dockerMgr.(composeChecker); ok {
+		services, err := checker.CheckCompose(ctx)
+		report.add(name+"compose", "services "+strings.Join(services, ", "), err)
+	}
+}
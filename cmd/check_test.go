@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"autopuller/docker"
+	"autopuller/env"
+	"autopuller/source"
+)
+
+// accessSource is a mock source that also verifies its credentials.
+type accessSource struct {
+	source.MockSource
+	accessErr error
+}
+
+// CheckAccess simulates checking the token against the repository.
+func (s *accessSource) CheckAccess(ctx context.Context) (string, error) {
+	return "owner/app is readable", s.accessErr
+}
+
+// stubCheckout replaces the checkout check with one that fails with err,
+// recording the branch it was asked for. It returns a function restoring the
+// real check.
+func stubCheckout(branch *string, err error) func() {
+	original := checkCheckout
+	checkCheckout = func(ctx context.Context, repoDir, b string) (string, error) {
+		*branch = b
+		return "master at abc123", err
+	}
+	return func() { checkCheckout = original }
+}
+
+// TestCheckProject_Pass tests that a working project passes every check.
+func TestCheckProject_Pass(t *testing.T) {
+	var branch string
+	defer stubCheckout(&branch, nil)()
+
+	var out bytes.Buffer
+	report := &checkReport{out: &out}
+	src := &accessSource{MockSource: source.MockSource{OverrideBranchSum: "abc123"}}
+	checkProject(context.Background(), report, env.Project{Name: "app", Branch: "master"}, src, &docker.MockDockerManager{Services: []string{"api", "web"}})
+
+	if !report.finish() {
+		t.Fatalf("Expected every check to pass, got:\n%s", out.String())
+	}
+	for _, line := range []string{"PASS  [app] access", "PASS  [app] branch: master is at abc123", "PASS  [app] checkout", "PASS  [app] compose: services api, web"} {
+		if !strings.Contains(out.String(), line) {
+			t.Fatalf("Expected the report to contain '%s', got:\n%s", line, out.String())
+		}
+	}
+	if branch != "master" {
+		t.Fatalf("Expected the checkout to be checked against master, got '%s'", branch)
+	}
+}
+
+// TestCheckProject_Fail tests that every failing check is reported.
+func TestCheckProject_Fail(t *testing.T) {
+	var branch string
+	defer stubCheckout(&branch, errors.New("checkout has 2 uncommitted changes"))()
+
+	var out bytes.Buffer
+	report := &checkReport{out: &out}
+	src := &accessSource{MockSource: source.MockSource{ShouldFailBranchSum: true}, accessErr: errors.New("the token was rejected")}
+	checkProject(context.Background(), report, env.Project{Name: "app"}, src, &docker.MockDockerManager{ShouldFail: true})
+
+	if report.finish() {
+		t.Fatalf("Expected the checks to fail, got:\n%s", out.String())
+	}
+	if report.failures != 4 {
+		t.Fatalf("Expected 4 failures, got %d:\n%s", report.failures, out.String())
+	}
+	if !strings.Contains(out.String(), "FAIL  [app] checkout: checkout has 2 uncommitted changes") {
+		t.Fatalf("Expected the checkout failure in the report, got:\n%s", out.String())
+	}
+}
+
+// TestCheckProject_Release tests that in release mode the newest release is
+// resolved and the checkout may be on any branch.
+func TestCheckProject_Release(t *testing.T) {
+	os.Setenv("RELEASE_MODE", "tags")
+	defer os.Unsetenv("RELEASE_MODE")
+	var branch string
+	defer stubCheckout(&branch, nil)()
+
+	var out bytes.Buffer
+	report := &checkReport{out: &out}
+	src := &source.MockSource{OverrideRelease: source.Release{Tag: "v1.2.0", Sha: "abc123"}}
+	checkProject(context.Background(), report, env.Project{}, src, &docker.MockDockerManager{})
+
+	if !strings.Contains(out.String(), "PASS  release: newest release is v1.2.0 (abc123)") {
+		t.Fatalf("Expected the release in the report, got:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "SKIP  access: not supported for SOURCE=github") {
+		t.Fatalf("Expected the access check to be skipped for a source without one, got:\n%s", out.String())
+	}
+	if branch != "" {
+		t.Fatalf("Expected no branch for the checkout in release mode, got '%s'", branch)
+	}
+}
+
+// TestCheckAccess_EverySource tests that every backend can check its access.
+func TestCheckAccess_EverySource(t *testing.T) {
+	defer os.Unsetenv("SOURCE")
+	for _, name := range []string{"github", "gitlab", "gitea", "forgejo", "bitbucket", "bitbucket-server", "git"} {
+		os.Setenv("SOURCE", name)
+		src, err := newSource(env.Project{})
+		if err != nil {
+			t.Fatalf("Expected no error for SOURCE=%s, got %v", name, err)
+		}
+		if _, ok := src.(accessChecker); !ok {
+			t.Errorf("Expected SOURCE=%s to check its access", name)
+		}
+	}
+}
+
+// TestRunCheck_InvalidConfig tests that an invalid config file fails the check.
+func TestRunCheck_InvalidConfig(t *testing.T) {
+	file, err := ioutil.TempFile("", "autopuller-*.yaml")
+	if err != nil {
+		t.Fatalf("Failed to create config file: %v", err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString("interval: 0\n")
+	file.Close()
+
+	os.Setenv("CONFIG_FILE", file.Name())
+	defer os.Unsetenv("CONFIG_FILE")
+
+	var out bytes.Buffer
+	if runCheck(context.Background(), &out) {
+		t.Fatalf("Expected the check to fail, got:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "FAIL  config: ") || !strings.Contains(out.String(), "1 check(s) failed.") {
+		t.Fatalf("Expected the config failure in the report, got:\n%s", out.String())
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"autopuller/env"
+	"autopuller/source"
+)
+
+// ciGate decides whether a commit's CI allows a deploy, remembering across
+// checks how long each commit has been pending and which commits were
+// rejected, so a failed or timed-out commit isn't queried again every interval.
+type ciGate struct {
+	pendingSince map[string]time.Time
+	rejected     map[string]string
+	now          func() time.Time
+	log          *log.Logger
+}
+
+// newCIGate creates an empty ciGate.
+func newCIGate() *ciGate {
+	return &ciGate{
+		pendingSince: map[string]time.Time{},
+		rejected:     map[string]string{},
+		now:          time.Now,
+		log:          log.New(log.Writer(), "", log.Flags()),
+	}
+}
+
+// ciPolicy builds the CI gate policy from the environment.
+func ciPolicy() source.CIPolicy {
+	return source.CIPolicy{
+		Mode:      env.GetCIPolicy(),
+		Required:  env.GetCIRequiredChecks(),
+		Optional:  env.GetCIOptionalChecks(),
+		Workflows: env.GetCIRequiredWorkflows(),
+		NoChecks:  env.GetCINoChecks(),
+	}
+}
+
+// passed reports whether the commit's CI passed. A pending commit is waited on
+// for up to CI_MAX_WAIT seconds; once it fails or times out it is rejected
+// without querying the source again. An error looking up the checks is
+// returned rather than treated as a failure, so the caller can retry, back
+// off or stop.
+func (g *ciGate) passed(ctx context.Context, src source.Source, sha string) (bool, error) {
+	if _, ok := g.rejected[sha]; ok {
+		return false, nil
+	}
+
+	policy := ciPolicy()
+	checks, workflows, err := src.CheckLastRun(ctx, sha, policy.Workflows)
+	if err != nil {
+		return false, fmt.Errorf("could not check CI for %s: %w", sha, err)
+	}
+	verdict := policy.Evaluate(checks, workflows)
+	g.log.Printf("CI verdict for %s: %s (%s)", sha, verdict.State, verdict.Reason)
+
+	switch verdict.State {
+	case source.CIPassed:
+		delete(g.pendingSince, sha)
+		return true, nil
+	case source.CIPending:
+		since, waiting := g.pendingSince[sha]
+		if !waiting {
+			g.log.Printf("CI for %s is pending (%s). Waiting before deploying.", sha, verdict.Reason)
+			g.pendingSince[sha] = g.now()
+			return false, nil
+		}
+		maxWait := time.Duration(env.GetCIMaxWait()) * time.Second
+		if maxWait > 0 && g.now().Sub(since) > maxWait {
+			g.reject(sha, "timed out after "+maxWait.String()+" ("+verdict.Reason+")")
+		}
+		return false, nil
+	default:
+		g.reject(sha, verdict.Reason)
+		return false, nil
+	}
+}
+
+// keepOnly forgets the pending and rejected commits other than shas, once
+// they are deployed or no longer among the commits that could be.
+func (g *ciGate) keepOnly(shas ...string) {
+	keep := map[string]bool{}
+	for _, sha := range shas {
+		keep[sha] = true
+	}
+	for sha := range g.pendingSince {
+		if !keep[sha] {
+			delete(g.pendingSince, sha)
+		}
+	}
+	for sha := range g.rejected {
+		if !keep[sha] {
+			delete(g.rejected, sha)
+		}
+	}
+}
+
+// forget drops what the gate remembers about the commit, so its CI is
+// queried again, as after a failed run was re-run.
+func (g *ciGate) forget(sha string) {
+	delete(g.pendingSince, sha)
+	delete(g.rejected, sha)
+}
+
+// reject remembers that the commit must not be deployed.
+func (g *ciGate) reject(sha, reason string) {
+	delete(g.pendingSince, sha)
+	g.rejected[sha] = reason
+	g.log.Printf("CI for %s failed: %s. Skipping this commit.", sha, reason)
+}
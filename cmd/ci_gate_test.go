@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"autopuller/source"
+)
+
+// TestCIGate_Passed tests that a passing commit is allowed every time it is checked.
+func TestCIGate_Passed(t *testing.T) {
+	g := newCIGate()
+	mockSource := &source.MockSource{OverrideCheckLastRun: true}
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if passed, err := g.passed(ctx, mockSource, "sha"); err != nil || !passed {
+			t.Fatalf("Expected the commit to pass, got %v (%v)", passed, err)
+		}
+	}
+}
+
+// TestCIGate_Failed tests that a failed commit is remembered:
+// - The first check queries GitHub and rejects the commit.
+// - Later checks don't query GitHub again.
+func TestCIGate_Failed(t *testing.T) {
+	g := newCIGate()
+	mockSource := &source.MockSource{OverrideCheckLastRun: false}
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		if passed, _ := g.passed(ctx, mockSource, "sha"); passed {
+			t.Fatalf("Expected the commit to be rejected")
+		}
+	}
+	if mockSource.CheckLastRunCalls != 1 {
+		t.Fatalf("Expected CI to be queried once, but got %d", mockSource.CheckLastRunCalls)
+	}
+}
+
+// TestCIGate_PendingTimeout tests that pending CI is waited on until CI_MAX_WAIT:
+// - While within the wait, GitHub keeps being queried.
+// - Once the wait runs out the commit is rejected and no longer queried.
+func TestCIGate_PendingTimeout(t *testing.T) {
+	os.Setenv("CI_MAX_WAIT", "60")
+	defer os.Unsetenv("CI_MAX_WAIT")
+
+	now := time.Now()
+	g := newCIGate()
+	g.now = func() time.Time { return now }
+	mockSource := &source.MockSource{OverridePending: true}
+
+	ctx := context.Background()
+	g.passed(ctx, mockSource, "sha")
+	now = now.Add(30 * time.Second)
+	g.passed(ctx, mockSource, "sha")
+	if _, ok := g.rejected["sha"]; ok {
+		t.Fatalf("Expected the commit to still be pending")
+	}
+
+	now = now.Add(60 * time.Second)
+	g.passed(ctx, mockSource, "sha")
+	if _, ok := g.rejected["sha"]; !ok {
+		t.Fatalf("Expected the commit to be rejected after timing out")
+	}
+
+	g.passed(ctx, mockSource, "sha")
+	if mockSource.CheckLastRunCalls != 3 {
+		t.Fatalf("Expected CI to be queried 3 times, but got %d", mockSource.CheckLastRunCalls)
+	}
+
+	// A pending commit that later passes is allowed
+	mockSource.OverridePending = false
+	mockSource.OverrideCheckLastRun = true
+	g.passed(ctx, &source.MockSource{OverridePending: true}, "other")
+	if passed, _ := g.passed(ctx, mockSource, "other"); !passed {
+		t.Fatalf("Expected the commit to pass once CI completed")
+	}
+}
+
+// TestCIGate_Error tests that an error looking up the checks is returned
+// rather than taken as a failure, so the commit is checked again later.
+func TestCIGate_Error(t *testing.T) {
+	g := newCIGate()
+	mockSource := &source.MockSource{ShouldFailCheckRun: true}
+
+	if _, err := g.passed(context.Background(), mockSource, "sha"); err == nil {
+		t.Fatalf("Expected an error, but got nil")
+	}
+	if _, ok := g.rejected["sha"]; ok {
+		t.Fatalf("Expected the commit not to be rejected after an error")
+	}
+}
+
+// TestCIGate_Forget tests that a forgotten commit is queried again, so a
+// re-run that passes lets it through.
+func TestCIGate_Forget(t *testing.T) {
+	g := newCIGate()
+	mockSource := &source.MockSource{}
+
+	ctx := context.Background()
+	if passed, _ := g.passed(ctx, mockSource, "sha"); passed {
+		t.Fatalf("Expected the commit to be rejected")
+	}
+	mockSource.GreenShas = []string{"sha"}
+	g.forget("sha")
+	if passed, err := g.passed(ctx, mockSource, "sha"); err != nil || !passed {
+		t.Fatalf("Expected the re-run commit to pass, got %v (%v)", passed, err)
+	}
+}
+
+// TestCIGate_KeepOnly tests that commits no longer deployable are forgotten.
+func TestCIGate_KeepOnly(t *testing.T) {
+	g := newCIGate()
+	ctx := context.Background()
+	g.passed(ctx, &source.MockSource{OverridePending: true}, "pending")
+	g.passed(ctx, &source.MockSource{}, "old")
+	g.passed(ctx, &source.MockSource{}, "tip")
+
+	g.keepOnly("tip")
+	if _, ok := g.pendingSince["pending"]; ok {
+		t.Fatalf("Expected the pending commit to be forgotten")
+	}
+	if _, ok := g.rejected["old"]; ok {
+		t.Fatalf("Expected the old rejected commit to be forgotten")
+	}
+	if _, ok := g.rejected["tip"]; !ok {
+		t.Fatalf("Expected the tip to stay rejected")
+	}
+
+	g.keepOnly()
+	if len(g.rejected) != 0 || len(g.pendingSince) != 0 {
+		t.Fatalf("Expected every commit to be forgotten, got %v and %v", g.rejected, g.pendingSince)
+	}
+}
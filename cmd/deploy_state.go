@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"autopuller/source"
+)
+
+// Stages of a deploy recorded in the deploy state.
+const (
+	stageUpdate  = "update"  // Updating the checkout
+	stageRestart = "restart" // Restarting the services
+)
+
+// maxRestartAttempts is how many times the restart of a deploy is tried,
+// counting the deploy itself, before it is given up on.
+const maxRestartAttempts = 3
+
+// deployState records a deploy in progress, so that one cut short by a
+// shutdown or a failed restart is finished by the next check instead of
+// leaving the checkout ahead of the running services.
+type deployState struct {
+	Sha      string    `json:"sha"`
+	Tip      string    `json:"tip"` // Remote tip or release when the deploy started
+	Stage    string    `json:"stage"`
+	Files    []string  `json:"files"`
+	Started  time.Time `json:"started"`
+	Attempts int       `json:"attempts"` // Failed restarts so far
+}
+
+// deployStateFile gets where the project's unfinished deploy is recorded, by
+// default in the checkout's git directory. In a worktree checkout .git is a
+// file, so the directory it points to is used.
+func (w *worker) deployStateFile() string {
+	if file := w.project.GetDeployStateFile(); file != "" {
+		return file
+	}
+	return filepath.Join(source.GitDir(w.project.GetRepoDir()), "autopuller-deploy.json")
+}
+
+// saveDeployState writes the deploy state. Failing to record it doesn't stop
+// the deploy.
+func (w *worker) saveDeployState(state deployState) {
+	path := w.deployStateFile()
+	data, _ := json.Marshal(state)
+	if err := ioutil.WriteFile(path+".tmp", data, 0644); err != nil {
+		w.log.Printf("Could not record deploy state: %v", err)
+		return
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		w.log.Printf("Could not record deploy state: %v", err)
+	}
+}
+
+// loadDeployState reads the deploy state, or returns nil when no deploy was
+// left unfinished.
+func (w *worker) loadDeployState() *deployState {
+	data, err := ioutil.ReadFile(w.deployStateFile())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			w.log.Printf("Could not read deploy state: %v", err)
+		}
+		return nil
+	}
+	var state deployState
+	if err := json.Unmarshal(data, &state); err != nil {
+		w.log.Printf("Ignoring unreadable deploy state: %v", err)
+		w.clearDeployState()
+		return nil
+	}
+	return &state
+}
+
+// clearDeployState removes the deploy state once the deploy is done.
+func (w *worker) clearDeployState() {
+	if err := os.Remove(w.deployStateFile()); err != nil && !os.IsNotExist(err) {
+		w.log.Printf("Could not clear deploy state: %v", err)
+	}
+}
+
+// deploy updates the checkout to sha with update, then restarts the services
+// affected by the changed files. tip is the commit the remote wanted deployed,
+// which differs from sha when falling back to a green commit. Its progress is
+// recorded so resumeDeploy can finish it if it is interrupted.
+func (w *worker) deploy(ctx context.Context, tip, sha string, diffs []string, update func() error) error {
+	// Services a given-up or superseded deploy didn't restart are restarted too
+	files := addMissing(w.runtimeFiles(diffs), w.carriedFiles)
+	w.saveDeployState(deployState{Sha: sha, Tip: tip, Stage: stageUpdate, Files: files, Started: time.Now()})
+	w.carriedFiles = nil
+	if err := update(); err != nil {
+		return err
+	}
+
+	if len(diffs) == 0 && len(files) == 0 {
+		w.log.Println("No files changed. Skipping restart.")
+		w.clearDeployState()
+		return nil
+	}
+	if len(files) == 0 {
+		w.log.Println("Only non-runtime files changed. Skipping restart.")
+		w.clearDeployState()
+		return nil
+	}
+
+	// Restart services using Docker Compose
+	state := deployState{Sha: sha, Tip: tip, Stage: stageRestart, Files: files, Started: time.Now()}
+	w.saveDeployState(state)
+	if err := w.restartServices(ctx, files); err != nil {
+		if ctx.Err() != nil {
+			w.log.Printf("Deploy of %s was interrupted; it will be resumed on the next start", sha)
+		} else {
+			state.Attempts++
+			w.saveDeployState(state)
+		}
+		return err
+	}
+	w.clearDeployState()
+	return nil
+}
+
+// addMissing appends the files of extra that aren't in files yet.
+func addMissing(files, extra []string) []string {
+	seen := map[string]bool{}
+	for _, file := range files {
+		seen[file] = true
+	}
+	for _, file := range extra {
+		if !seen[file] {
+			seen[file] = true
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// resumeDeploy finishes a deploy left unfinished by a previous check or run,
+// given the tip or release the remote now wants deployed. If the checkout was updated,
+// its services are restarted; if the update itself didn't complete, the state
+// is dropped and the regular check deploys the commit again. A deploy whose
+// restart keeps failing is given up on after maxRestartAttempts, and one
+// superseded by a newer commit is dropped so that commit can be deployed;
+// either way its services are restarted by the next deploy.
+func (w *worker) resumeDeploy(ctx context.Context, tip string) error {
+	state := w.loadDeployState()
+	if state == nil {
+		return nil
+	}
+
+	if state.Stage == stageUpdate {
+		currentSum, err := w.src.GetCurrentSum("")
+		if err != nil {
+			return err
+		}
+		if currentSum != state.Sha {
+			w.log.Printf("Deploy of %s was interrupted while updating the checkout. Deploying again.", state.Sha)
+			w.carriedFiles = addMissing(w.carriedFiles, state.Files)
+			w.clearDeployState()
+			return nil
+		}
+	}
+
+	// Older states only record the deployed commit
+	stateTip := state.Tip
+	if stateTip == "" {
+		stateTip = state.Sha
+	}
+
+	switch {
+	case tip != stateTip:
+		w.log.Printf("Deploy of %s is superseded by %s, which restarts its services instead.", state.Sha, tip)
+		w.carriedFiles = addMissing(w.carriedFiles, state.Files)
+		w.clearDeployState()
+		return nil
+	case state.Attempts >= maxRestartAttempts:
+		w.log.Printf("Giving up restarting services for %s after %d attempts; the next deploy restarts them.", state.Sha, state.Attempts)
+		w.carriedFiles = addMissing(w.carriedFiles, state.Files)
+		w.clearDeployState()
+		return nil
+	}
+
+	if len(state.Files) > 0 {
+		w.log.Printf("Resuming the deploy of %s started %s: restarting services.", state.Sha, state.Started.Format(time.RFC3339))
+		if err := w.restartServices(ctx, state.Files); err != nil {
+			if ctx.Err() == nil {
+				state.Attempts++
+				w.saveDeployState(*state)
+			}
+			return err
+		}
+	}
+	w.clearDeployState()
+	return nil
+}
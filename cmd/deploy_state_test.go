@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"autopuller/docker"
+	"autopuller/env"
+	"autopuller/source"
+)
+
+// useDeployStateFile points DEPLOY_STATE_FILE at a temporary file and returns
+// a cleanup function.
+func useDeployStateFile(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "deploy_state")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	os.Setenv("DEPLOY_STATE_FILE", filepath.Join(dir, "deploy.json"))
+	return func() {
+		os.Unsetenv("DEPLOY_STATE_FILE")
+		os.RemoveAll(dir)
+	}
+}
+
+// TestCheckForUpdates_ResumesFailedRestart tests that a restart that failed
+// after the pull is retried by the next check, even though the checkout is
+// already up to date by then.
+func TestCheckForUpdates_ResumesFailedRestart(t *testing.T) {
+	defer useDeployStateFile(t)()
+
+	mockSource := &source.MockSource{
+		OverrideBranchSum:    "new_sha",
+		OverrideCurrentSum:   "old_sha",
+		OverrideCheckLastRun: true,
+		FileDifferences:      []string{"app.go"},
+	}
+	mockDocker := &docker.MockDockerManager{ShouldFail: true}
+
+	w := newWorker(env.Project{}, mockSource, mockDocker)
+	ctx := context.Background()
+	if err := w.checkForUpdates(ctx); err == nil {
+		t.Fatalf("Expected error due to Docker restart failure, but got nil")
+	}
+	if state := w.loadDeployState(); state == nil || state.Stage != stageRestart || state.Sha != "new_sha" {
+		t.Fatalf("Expected the restart of new_sha to be recorded, got %+v", state)
+	}
+
+	// The pull went through, so the next check sees no new commit
+	mockSource.OverrideCurrentSum = "new_sha"
+	mockDocker.ShouldFail = false
+	if err := w.checkForUpdates(ctx); err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if mockDocker.Restarts != 2 {
+		t.Fatalf("Expected the restart to be resumed, but got %d restarts", mockDocker.Restarts)
+	}
+	if state := w.loadDeployState(); state != nil {
+		t.Fatalf("Expected the deploy state to be cleared, got %+v", state)
+	}
+}
+
+// TestCheckForUpdates_FixAfterFailedRestart tests that a commit whose
+// restart keeps failing doesn't block the commit fixing it: the fix is
+// fetched and deployed, restarting the services of both.
+func TestCheckForUpdates_FixAfterFailedRestart(t *testing.T) {
+	defer useDeployStateFile(t)()
+	os.Setenv("DOCKER_SERVICE_PATHS", "api=app.go;web=fix.go")
+	defer os.Unsetenv("DOCKER_SERVICE_PATHS")
+
+	mockSource := &source.MockSource{
+		OverrideBranchSum:    "broken_sha",
+		OverrideCurrentSum:   "old_sha",
+		OverrideCheckLastRun: true,
+		FileDifferences:      []string{"app.go"},
+	}
+	mockDocker := &docker.MockDockerManager{ShouldFail: true}
+	w := newWorker(env.Project{}, mockSource, mockDocker)
+	ctx := context.Background()
+
+	// The build of the broken commit fails, and so does the retry
+	if err := w.checkForUpdates(ctx); err == nil {
+		t.Fatalf("Expected error due to Docker restart failure, but got nil")
+	}
+	mockSource.OverrideCurrentSum = "broken_sha"
+	if err := w.checkForUpdates(ctx); err == nil {
+		t.Fatalf("Expected the resumed restart to fail, but got nil")
+	}
+	if state := w.loadDeployState(); state == nil || state.Attempts != 2 {
+		t.Fatalf("Expected 2 failed restarts to be recorded, got %+v", state)
+	}
+
+	// The fix arrives and builds
+	mockSource.OverrideBranchSum = "fix_sha"
+	mockSource.FileDifferences = []string{"fix.go"}
+	mockDocker.ShouldFail = false
+	if err := w.checkForUpdates(ctx); err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if mockDocker.Restarts != 3 {
+		t.Fatalf("Expected the fix to be deployed without retrying the broken restart, got %d restarts", mockDocker.Restarts)
+	}
+	if services := mockDocker.RestartedServices; len(services) != 2 || services[0] != "api" || services[1] != "web" {
+		t.Fatalf("Expected the services of both commits to restart, got %v", services)
+	}
+	if state := w.loadDeployState(); state != nil {
+		t.Fatalf("Expected the deploy state to be cleared, got %+v", state)
+	}
+}
+
+// TestCheckForUpdates_GivesUpRestart tests that a restart that keeps failing
+// is given up on after maxRestartAttempts.
+func TestCheckForUpdates_GivesUpRestart(t *testing.T) {
+	defer useDeployStateFile(t)()
+
+	mockSource := &source.MockSource{
+		OverrideBranchSum:    "broken_sha",
+		OverrideCurrentSum:   "old_sha",
+		OverrideCheckLastRun: true,
+		FileDifferences:      []string{"app.go"},
+	}
+	mockDocker := &docker.MockDockerManager{ShouldFail: true}
+	w := newWorker(env.Project{}, mockSource, mockDocker)
+	ctx := context.Background()
+
+	w.checkForUpdates(ctx)
+	mockSource.OverrideCurrentSum = "broken_sha"
+	for i := 1; i < maxRestartAttempts; i++ {
+		if err := w.checkForUpdates(ctx); err == nil {
+			t.Fatalf("Expected the resumed restart to fail, but got nil")
+		}
+	}
+	if err := w.checkForUpdates(ctx); err != nil {
+		t.Fatalf("Expected the restart to be given up on, but got: %v", err)
+	}
+	if mockDocker.Restarts != maxRestartAttempts {
+		t.Fatalf("Expected %d restarts, got %d", maxRestartAttempts, mockDocker.Restarts)
+	}
+	if state := w.loadDeployState(); state != nil {
+		t.Fatalf("Expected the deploy state to be cleared, got %+v", state)
+	}
+}
+
+// TestResumeDeploy_InterruptedUpdate tests that a deploy interrupted before
+// the checkout was updated is dropped, leaving the check to deploy again.
+func TestResumeDeploy_InterruptedUpdate(t *testing.T) {
+	defer useDeployStateFile(t)()
+
+	mockSource := &source.MockSource{OverrideCurrentSum: "old_sha"}
+	mockDocker := &docker.MockDockerManager{}
+	w := newWorker(env.Project{}, mockSource, mockDocker)
+
+	w.saveDeployState(deployState{Sha: "new_sha", Stage: stageUpdate, Files: []string{"app.go"}, Started: time.Now()})
+	if err := w.resumeDeploy(context.Background(), "new_sha"); err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if mockDocker.Restarts != 0 {
+		t.Fatalf("Expected no restart, but got %d", mockDocker.Restarts)
+	}
+	if state := w.loadDeployState(); state != nil {
+		t.Fatalf("Expected the deploy state to be cleared, got %+v", state)
+	}
+}
+
+// TestDeployState_Worktree tests that the deploy state of a worktree checkout,
+// whose .git is a file, is kept in the worktree's git directory.
+func TestDeployState_Worktree(t *testing.T) {
+	root, err := ioutil.TempDir("", "worktree")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(root)
+	gitDir := filepath.Join(root, "main", ".git", "worktrees", "wt")
+	os.MkdirAll(gitDir, 0755)
+	os.MkdirAll(filepath.Join(root, "wt"), 0755)
+	ioutil.WriteFile(filepath.Join(root, "wt", ".git"), []byte("gitdir: ../main/.git/worktrees/wt\n"), 0644)
+
+	w := newWorker(env.Project{Name: "app", RepoDir: filepath.Join(root, "wt")}, &source.MockSource{}, &docker.MockDockerManager{})
+	w.saveDeployState(deployState{Sha: "new_sha", Stage: stageRestart})
+
+	if _, err := os.Stat(filepath.Join(gitDir, "autopuller-deploy.json")); err != nil {
+		t.Fatalf("Expected the deploy state in the worktree's git directory, got %v", err)
+	}
+	if state := w.loadDeployState(); state == nil || state.Sha != "new_sha" {
+		t.Fatalf("Expected the deploy state to be read back, got %+v", state)
+	}
+}
+
+// TestShutdownOnSignal tests that a signal stops the loop at once but only
+// cancels the check after the grace period, or on a second signal.
+func TestShutdownOnSignal(t *testing.T) {
+	signals := make(chan os.Signal, 2)
+	ctx, stopping := shutdownOnSignal(signals, 50*time.Millisecond)
+
+	signals <- syscall.SIGTERM
+	select {
+	case <-stopping:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the loop to be stopped by the signal")
+	}
+	if ctx.Err() != nil {
+		t.Fatalf("Expected the check to keep running during the grace period")
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the check to be cancelled after the grace period")
+	}
+
+	signals = make(chan os.Signal, 2)
+	ctx, _ = shutdownOnSignal(signals, time.Hour)
+	signals <- syscall.SIGINT
+	signals <- syscall.SIGINT
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("Expected a second signal to cancel the check")
+	}
+}
@@ -9,13 +9,89 @@ import (
 // Content for the .env.sample file
 const envSampleContent = `# .env.sample
 
+# Optional: YAML config file; variables set here or in the environment override its keys
+CONFIG_FILE=
+
+# Hosting backend of the repository: github, gitlab, gitea (also forgejo), bitbucket, bitbucket-server or git (default: github)
+SOURCE=github
+
 # GitHub API token with permissions to access the repository
 GITHUBKEY=
 
-# GitHub repository name, without the www. or https://github.com/
+# Optional: authenticate as a GitHub App instead of with GITHUBKEY
+# App ID, path of the app's private key (PEM) and, optionally, the installation ID (looked up from REPONAME if empty)
+GITHUB_APP_ID=
+GITHUB_APP_PRIVATE_KEY=
+GITHUB_APP_INSTALLATION_ID=
+
+# GitHub Enterprise Server only: API base of the instance (default: https://api.github.com)
+# Example: https://ghe.example.com/api/v3 (given only the host, /api/v3 is added)
+GITHUB_API_URL=
+
+# Optional: PEM file of extra CA certificates to trust for API requests (e.g. an internal CA)
+CA_BUNDLE=
+
+# Repository name, without the www. or https://github.com/ (for GitLab, the project path)
 # Example: if the repository is https://github.com/user/repo, set this to user/repo
 REPONAME=amunchet/autopuller-go
 
+# GitLab only: URL of the GitLab instance (default: https://gitlab.com) and API token
+GITLAB_URL=
+GITLABKEY=
+
+# Gitea/Forgejo only: URL of the instance (e.g. https://gitea.example.com) and API token
+GITEA_URL=
+GITEAKEY=
+
+# Bitbucket only: access token, or app password together with BITBUCKET_USER
+# For bitbucket-server, also the URL of the instance (e.g. https://bitbucket.example.com)
+BITBUCKETKEY=
+BITBUCKET_USER=
+BITBUCKET_URL=
+
+# Plain git only: how to gate deploys without a CI API (default: none)
+# none: deploy every commit; marker: deploy once GIT_CI_MARKER exists ({sha} is replaced with the commit)
+# command: run GIT_CI_COMMAND with SHA set; exit 0 passes, exit 75 means pending, anything else fails
+GIT_CI_GATE=none
+GIT_CI_MARKER=
+GIT_CI_COMMAND=
+
+# Branch to track for new commits (default: master)
+# Example: main
+BRANCH=master
+
+# Optional: When the tip of BRANCH fails CI, deploy the newest earlier commit that passed (default: false)
+FALLBACK_TO_GREEN=false
+
+# Optional: Deploy tagged releases instead of the branch tip (set to "tags" or "releases")
+# "tags" considers every version tag, "releases" only published GitHub releases
+RELEASE_MODE=
+
+# Optional: Version constraint releases must match (e.g. ~1.4, ^2, >=1.2 <2)
+RELEASE_CONSTRAINT=
+
+# Optional: Allow prerelease versions such as 1.5.0-rc.1 to be deployed (default: false)
+RELEASE_PRERELEASE=false
+
+# Optional: Which CI checks gate a deploy: "all" reported checks must pass, or only the "required" ones (default: all)
+CI_POLICY=all
+
+# Optional: Comma-separated check run or status names that must pass (e.g. test,build)
+CI_REQUIRED_CHECKS=
+
+# Optional: Comma-separated check names whose result is ignored (e.g. codecov/patch)
+CI_OPTIONAL_CHECKS=
+
+# Optional (GitHub only, rejected for other sources): Comma-separated workflow names or file paths that must all conclude success (e.g. Go Test,.github/workflows/build.yml)
+CI_REQUIRED_WORKFLOWS=
+
+# Optional: Seconds to wait for queued or in-progress CI before skipping the commit, 0 waits forever (default: 3600)
+CI_MAX_WAIT=3600
+
+# Optional: How a commit without any checks is treated: "pending" waits for checks (and is skipped after CI_MAX_WAIT),
+# "pass" deploys it, for repositories without CI (default: pending)
+CI_NO_CHECKS=pending
+
 # Directory where the GitHub repository is cloned locally
 # Example: /path/to/local/repo
 REPODIR=.
@@ -24,13 +100,52 @@ REPODIR=.
 # Example: /path/to/docker-compose
 DOCKERDIR=./docker/sample
 
+# Optional: YAML file listing several projects to watch from one daemon, each with a name and
+# its own repo, branch, repodir, dockerdir and interval (unset fields use the values above)
+PROJECTS_FILE=
+
 # Interval in seconds between checks for new commits (default: 60 seconds)
 INTERVAL=60
 
+# Optional: Most seconds added at random to each interval, so several autopullers don't poll in lockstep (default: 0)
+INTERVAL_JITTER=0
+
+# Optional: Consecutive failed checks double the interval, up to this many seconds (default: 900)
+INTERVAL_MAX_BACKOFF=900
+
+# Optional: Seconds a deploy in progress may keep running after SIGTERM/SIGINT before it is cancelled (default: 300)
+# Keep it below systemd's TimeoutStopSec (360 in the generated unit)
+SHUTDOWN_GRACE_PERIOD=300
+
+# Optional: File recording an unfinished deploy, so it is resumed on the next start (default: autopuller-deploy.json in the checkout's git directory)
+DEPLOY_STATE_FILE=
+
+# Optional: Address to listen on for GitHub push and workflow_run webhooks at /webhook (e.g. :8080)
+# Webhook payloads must be signed with WEBHOOK_SECRET; polling continues every WEBHOOK_POLL_INTERVAL seconds (default: 900)
+WEBHOOK_LISTEN=
+WEBHOOK_SECRET=
+WEBHOOK_POLL_INTERVAL=900
+
+
+# Optional: Comma-separated globs of changed files that trigger a container rebuild (default: every file)
+# Example: cmd/**,go.mod,Dockerfile
+DEPLOY_INCLUDE=
+
+# Optional: Comma-separated globs of changed files that only update the checkout without a rebuild
+# Example: docs/**,*.md,.github/**
+DEPLOY_EXCLUDE=
 
 # Docker Compose command: override the default "docker-compose" (e.g. "docker compose")
 DOCKERCOMMAND=docker-compose
 
+# Optional: Only rebuild the compose services whose paths changed, written as service=glob,glob;service=glob
+# Changes outside every service's paths restart the whole project
+# Example: api=backend/**,shared/**;web=frontend/**
+DOCKER_SERVICE_PATHS=
+
+# Optional: Derive each service's paths from its build context in the compose file (default: false)
+DOCKER_SERVICES_FROM_COMPOSE=false
+
 # Optional: Command for sending email notifications (default: 'mail -s')
 SENDMAIL_CMD=mail -s
 
@@ -6,66 +6,251 @@ import (
 	"log"
 	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"autopuller/bitbucket"
 	"autopuller/docker"
 	"autopuller/env"
+	"autopuller/gitea"
 	"autopuller/github"
+	"autopuller/gitlab"
 	"autopuller/logger"
+	"autopuller/pathfilter"
+	"autopuller/plaingit"
+	"autopuller/source"
 )
 
-func checkForUpdates(ctx context.Context, gitHub github.GitHubAPI, dockerMgr docker.DockerManager) error {
-	// Get the master commit from GitHub
-	masterSum, err := gitHub.GetMasterSum(ctx)
+// checkForUpdates deploys the project's new commit or release, if any.
+func (w *worker) checkForUpdates(ctx context.Context) error {
+	// Sources and docker log with the project's prefix too
+	ctx = source.WithLogger(ctx, w.log)
+	src := w.src
+
+	// A re-run CI may pass where it failed before
+	w.forgetReruns()
+
+	// Follow tagged releases instead of the branch tip if configured
+	if env.GetReleaseMode() != "" {
+		return w.checkForRelease(ctx)
+	}
+
+	// Branch to track
+	branch := w.project.GetBranch()
+
+	// Get the branch commit from the remote
+	branchSum, err := src.GetBranchSum(ctx, branch)
 	if err != nil {
 		return err
 	}
 
+	// Finish a deploy that was interrupted or whose restart failed, unless a
+	// newer commit supersedes it. A failure is only reported once the check
+	// is done, so a fix pushed meanwhile is still deployed.
+	resumeErr := w.resumeDeploy(ctx, branchSum)
+
 	// Get the current commit (locally)
-	currentSum, err := gitHub.GetCurrentSum()
+	currentSum, err := src.GetCurrentSum(branch)
 	if err != nil {
 		return err
 	}
 
 	// Check if there's a new commit
-	if masterSum != currentSum {
-		log.Printf("Differences found between master (%s) and current (%s)", masterSum, currentSum)
+	if branchSum != currentSum {
+		w.log.Printf("Differences found between %s (%s) and current (%s)", branch, branchSum, currentSum)
 
-		// Check if last run was successful
-		if passed, err := gitHub.CheckLastRun(ctx, masterSum); err == nil && passed {
-			log.Println("Last run passed, proceeding with update.")
+		// Only the tip can be deployed, unless falling back to an earlier commit
+		if !env.GetGreenFallback() {
+			w.gate.keepOnly(branchSum)
+		}
 
-			// Check for file differences
-			diffs, err := gitHub.CheckDifferences(ctx, currentSum, masterSum)
+		// Check if last run was successful, otherwise fall back to the newest green commit if configured
+		targetSum := branchSum
+		passed, err := w.gate.passed(ctx, src, branchSum)
+		if err != nil {
+			return err
+		}
+		if !passed {
+			if !env.GetGreenFallback() {
+				return resumeErr
+			}
+			targetSum, err = w.newestGreenCommit(ctx, currentSum, branchSum)
 			if err != nil {
 				return err
 			}
-
-			if len(diffs) == 0 {
-				log.Println("No files changed. Exiting.")
-				return nil
+			if targetSum == "" {
+				return resumeErr
 			}
+			w.log.Printf("Tip of %s is not green, falling back to %s.", branch, targetSum)
+		}
+		w.log.Println("Last run passed, proceeding with update.")
 
-			// Run git pull to update the repository
-			repoDir := os.Getenv("REPODIR")
-			if err := gitHub.RunGitPull(ctx, repoDir); err != nil {
-				return err
-			}
+		// Check for file differences
+		diffs, err := src.CheckDifferences(ctx, currentSum, targetSum)
+		if err != nil {
+			return err
+		}
 
-			// Restart services using Docker Compose
-			err = dockerMgr.RestartServices(ctx)
-			if err != nil {
-				return err
-			}
+		if len(diffs) == 0 {
+			w.log.Println("No files changed. Exiting.")
+			return resumeErr
+		}
 
-		} else {
-			log.Println("Last run failed or not completed yet. Skipping restart.")
+		// Run git pull to update the repository, or fast-forward to the green commit
+		repoDir := w.project.GetRepoDir()
+		err = w.deploy(ctx, branchSum, targetSum, diffs, func() error {
+			if targetSum == branchSum {
+				return src.RunGitPull(ctx, repoDir, branch)
+			}
+			return src.FastForward(ctx, repoDir, branch, targetSum)
+		})
+		if err == nil {
+			err = resumeErr
 		}
+		return err
 	} else {
-		//log.Println("No differences found. Nothing to do.")
+		// Nothing is waiting to be deployed
+		w.gate.keepOnly()
 	}
 
-	return nil
+	return resumeErr
+}
+
+// newestGreenCommit walks the commits after currentSum up to (but excluding)
+// branchSum from newest to oldest and returns the first whose CI passed, or
+// an empty string if none did.
+func (w *worker) newestGreenCommit(ctx context.Context, currentSum, branchSum string) (string, error) {
+	commits, err := w.src.ListCommits(ctx, currentSum, branchSum)
+	if err != nil {
+		return "", err
+	}
+	w.gate.keepOnly(append(commits, branchSum)...)
+	for i := len(commits) - 1; i >= 0; i-- {
+		if commits[i] == branchSum {
+			continue
+		}
+		passed, err := w.gate.passed(ctx, w.src, commits[i])
+		if err != nil {
+			return "", err
+		}
+		if passed {
+			return commits[i], nil
+		}
+	}
+	return "", nil
+}
+
+// checkForRelease deploys the newest release matching RELEASE_CONSTRAINT by checking out its tag.
+func (w *worker) checkForRelease(ctx context.Context) error {
+	src := w.src
+
+	// Find the release to deploy
+	constraint := env.GetReleaseConstraint()
+	release, err := source.LatestRelease(ctx, src, constraint, env.GetReleasePrerelease())
+	if err != nil {
+		return err
+	}
+	w.log.Printf("Latest release matching %q is %s (%s)", constraint, release.Tag, release.Sha)
+
+	// Finish a deploy that was interrupted or whose restart failed, unless a
+	// newer release supersedes it
+	resumeErr := w.resumeDeploy(ctx, release.Sha)
+
+	// Get the current commit (locally); any branch or detached HEAD is accepted
+	currentSum, err := src.GetCurrentSum("")
+	if err != nil {
+		return err
+	}
+
+	if release.Sha == currentSum {
+		w.gate.keepOnly()
+		return resumeErr
+	}
+	w.log.Printf("New release %s (%s) differs from current (%s)", release.Tag, release.Sha, currentSum)
+	w.gate.keepOnly(release.Sha)
+
+	// Check if last run was successful
+	passed, err := w.gate.passed(ctx, src, release.Sha)
+	if err != nil || !passed {
+		return err
+	}
+	w.log.Println("Last run passed, proceeding with update.")
+
+	// Check for file differences
+	diffs, err := src.CheckDifferences(ctx, currentSum, release.Sha)
+	if err != nil {
+		return err
+	}
+
+	// Check out the release tag
+	repoDir := w.project.GetRepoDir()
+	return w.deploy(ctx, release.Sha, release.Sha, diffs, func() error {
+		return src.CheckoutTag(ctx, repoDir, release.Tag)
+	})
+}
+
+// runtimeFiles returns the changed files that pass the project's include and
+// exclude rules, logging the rule that decided each file.
+func (w *worker) runtimeFiles(diffs []string) []string {
+	filter := pathfilter.Filter{Include: w.project.GetDeployInclude(), Exclude: w.project.GetDeployExclude()}
+	var files []string
+	for _, file := range diffs {
+		match, rule := filter.Match(file)
+		if match {
+			files = append(files, file)
+		}
+		w.log.Printf("Changed file %s: restart %v (%s)", file, match, rule)
+	}
+	return files
+}
+
+// restartServices restarts only the compose services affected by the changed
+// files when the project's service paths, or its compose file, map paths to
+// services, and the whole project otherwise.
+func (w *worker) restartServices(ctx context.Context, files []string) error {
+	dockerMgr := w.docker
+	servicePaths := w.project.GetDockerServicePaths()
+	if w.project.GetDockerServicesFromCompose() {
+		contexts, err := docker.ServiceContexts(w.project.GetDockerDir(), w.project.GetRepoDir())
+		if err != nil {
+			return err
+		}
+		for service, globs := range contexts {
+			servicePaths[service] = append(servicePaths[service], globs...)
+		}
+	}
+	if len(servicePaths) == 0 {
+		return dockerMgr.RestartServices(ctx, nil)
+	}
+
+	services, ok := docker.AffectedServices(files, servicePaths)
+	if !ok {
+		w.log.Println("Changed files not mapped to a service. Restarting all services.")
+		return dockerMgr.RestartServices(ctx, nil)
+	}
+	w.log.Printf("Restarting affected services: %v", services)
+	return dockerMgr.RestartServices(ctx, services)
+}
+
+// newSource creates the source backend selected by SOURCE for the project.
+func newSource(project env.Project) (source.Source, error) {
+	checkout := source.GitCheckout{Repo: project.Repo, Dir: project.RepoDir}
+	switch env.GetSource() {
+	case "github":
+		return &github.RealGitHubAPI{GitCheckout: checkout}, nil
+	case "gitlab":
+		return &gitlab.RealGitLabAPI{GitCheckout: checkout}, nil
+	case "gitea", "forgejo":
+		return &gitea.RealGiteaAPI{GitCheckout: checkout}, nil
+	case "bitbucket":
+		return &bitbucket.RealBitbucketCloudAPI{GitCheckout: checkout}, nil
+	case "bitbucket-server":
+		return &bitbucket.RealBitbucketServerAPI{GitCheckout: checkout}, nil
+	case "git":
+		return &plaingit.RealGitAPI{GitCheckout: checkout}, nil
+	}
+	return nil, fmt.Errorf("unknown SOURCE %q", env.GetSource())
 }
 
 var version = "dev"
@@ -81,6 +266,7 @@ func main() {
 			fmt.Println(" systemd - generates the systemd file")
 			fmt.Println(" env - generates the sample env file")
 			fmt.Println(" version - lists the build version")
+			fmt.Println(" check (or doctor) - validates the setup and exits")
 			return
 		}
 		if strings.Contains(arg, "env") {
@@ -109,6 +295,13 @@ func main() {
 			return
 		}
 
+		if arg == "check" || arg == "doctor" {
+			if !runCheck(context.Background(), os.Stdout) {
+				os.Exit(1)
+			}
+			return
+		}
+
 	}
 
 	log.Println(version)
@@ -117,27 +310,73 @@ func main() {
 	logger.InitLogger("autopuller.log")
 
 	// Load Dot Env
-	if err := env.LoadEnv(); err != nil {
+	if err := env.LoadEnv(); err != nil && env.GetConfigFile() == "" {
 		log.Fatalf("Error loading ENV: %v", err)
 	}
 
-	// Context for Docker and GitHub operations
-	ctx := context.Background()
+	// Read the config file, if any, with the environment on top
+	if _, err := env.LoadConfig(); err != nil {
+		log.Fatalf("Error loading config: %v", err)
+	}
+
+	if err := source.UseCABundle(env.GetCABundle()); err != nil {
+		log.Fatalf("Error loading CA bundle: %v", err)
+	}
 
-	// Create real GitHub and Docker implementations
-	gitHub := &github.RealGitHubAPI{}
-	dockerMgr := &docker.RealDockerManager{}
+	// Context for Docker and source operations, cancelled once a shutdown's grace period is over
+	ctx, stopping := watchSignals(time.Duration(env.GetShutdownGracePeriod()) * time.Second)
 
-	// Main loop
-	for {
-		err := checkForUpdates(ctx, gitHub, dockerMgr)
+	// Create a worker with real source and Docker implementations for each project
+	projects, err := env.LoadProjects()
+	if err != nil {
+		log.Fatalf("Error loading projects: %v", err)
+	}
+	var workers []*worker
+	for _, project := range projects {
+		src, err := newSource(project)
 		if err != nil {
-			log.Fatalf("Error in checking updates: %v", err)
+			log.Fatalf("Error creating source: %v", err)
+		}
+		workers = append(workers, newWorker(project, src, &docker.RealDockerManager{Dir: project.DockerDir}))
+	}
+
+	// Webhooks trigger a check right away, leaving polling as a safety net
+	webhooks := env.GetWebhookListen() != ""
+	if webhooks {
+		handler := &webhookHandler{secret: []byte(env.GetWebhookSecret())}
+		for _, w := range workers {
+			handler.targets = append(handler.targets, webhookTarget{
+				repo:      w.project.GetRepo(),
+				branch:    w.project.GetBranch(),
+				releases:  env.GetReleaseMode() != "",
+				trigger:   w.trigger,
+				completed: w.ciCompleted,
+			})
 		}
+		startWebhookServer(env.GetWebhookListen(), handler, stopping)
+	}
 
+	// Run the projects side by side until shut down
+	var wg sync.WaitGroup
+	var failed int32
+	for _, w := range workers {
 		// Sleep between checks
-		interval := 60 // Hardcoded for simplicity, you can load this from env if needed
-		//log.Printf("Sleeping for %d seconds...", interval)
-		time.Sleep(time.Duration(interval) * time.Second)
+		interval := w.project.GetInterval()
+		if webhooks {
+			interval = env.GetWebhookPollInterval()
+		}
+
+		wg.Add(1)
+		go func(w *worker, interval int) {
+			defer wg.Done()
+			if err := w.run(ctx, stopping, interval); err != nil {
+				atomic.StoreInt32(&failed, 1)
+			}
+		}(w, interval)
+	}
+	wg.Wait()
+	if atomic.LoadInt32(&failed) != 0 {
+		log.Fatalf("Stopped after a fatal error")
 	}
+	log.Println("Shut down.")
 }
@@ -1,11 +1,17 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"autopuller/docker"
-	"autopuller/github"
+	"autopuller/env"
+	"autopuller/source"
 )
 
 // TestCheckForUpdates_Success tests the happy path scenario where everything works as expected:
@@ -13,8 +19,8 @@ import (
 // - The last GitHub action run was successful.
 // - Docker services restart successfully.
 func TestCheckForUpdates_Success(t *testing.T) {
-	// Mock GitHub API returning a new master commit
-	mockGitHub := &github.MockGitHubAPI{
+	// Mock GitHub API returning a new branch commit
+	mockSource := &source.MockSource{
 		// Customize mock responses as needed
 	}
 
@@ -25,19 +31,19 @@ func TestCheckForUpdates_Success(t *testing.T) {
 
 	// Call the function under test
 	ctx := context.Background()
-	err := checkForUpdates(ctx, mockGitHub, mockDocker)
+	err := newWorker(env.Project{}, mockSource, mockDocker).checkForUpdates(ctx)
 	if err != nil {
 		t.Fatalf("Expected no error, but got: %v", err)
 	}
 }
 
 // TestCheckForUpdates_NoNewCommits tests the scenario where there are no new commits to pull from GitHub:
-// - The current and master commit SHAs are the same.
+// - The current and branch commit SHAs are the same.
 // - No services should restart.
 func TestCheckForUpdates_NoNewCommits(t *testing.T) {
 	// Customize MockGitHubAPI to simulate no new commits
-	mockGitHub := &github.MockGitHubAPI{
-		OverrideMasterSum:  "same_sha", // Simulate master commit is the same
+	mockSource := &source.MockSource{
+		OverrideBranchSum:  "same_sha", // Simulate branch commit is the same
 		OverrideCurrentSum: "same_sha", // Simulate current commit is the same
 	}
 
@@ -48,7 +54,7 @@ func TestCheckForUpdates_NoNewCommits(t *testing.T) {
 
 	// Call the function under test
 	ctx := context.Background()
-	err := checkForUpdates(ctx, mockGitHub, mockDocker)
+	err := newWorker(env.Project{}, mockSource, mockDocker).checkForUpdates(ctx)
 	if err != nil {
 		t.Fatalf("Expected no error, but got: %v", err)
 	}
@@ -60,8 +66,8 @@ func TestCheckForUpdates_NoNewCommits(t *testing.T) {
 // - Services should not restart.
 func TestCheckForUpdates_LastRunFailed(t *testing.T) {
 	// Mock GitHub API returning a new commit but with a failed GitHub Actions run
-	mockGitHub := &github.MockGitHubAPI{
-		OverrideMasterSum:    "new_sha",
+	mockSource := &source.MockSource{
+		OverrideBranchSum:    "new_sha",
 		OverrideCurrentSum:   "old_sha",
 		OverrideCheckLastRun: false, // Simulate that the last run failed
 	}
@@ -71,20 +77,39 @@ func TestCheckForUpdates_LastRunFailed(t *testing.T) {
 
 	// Call the function under test
 	ctx := context.Background()
-	err := checkForUpdates(ctx, mockGitHub, mockDocker)
+	err := newWorker(env.Project{}, mockSource, mockDocker).checkForUpdates(ctx)
 	if err != nil {
 		t.Fatalf("Expected no error, but got: %v", err)
 	}
 }
 
+// TestCheckForUpdates_CheckRunError tests that an error looking up CI is
+// returned instead of being taken as a failed run.
+func TestCheckForUpdates_CheckRunError(t *testing.T) {
+	mockSource := &source.MockSource{
+		OverrideBranchSum:  "new_sha",
+		OverrideCurrentSum: "old_sha",
+		ShouldFailCheckRun: true,
+	}
+	mockDocker := &docker.MockDockerManager{}
+
+	err := newWorker(env.Project{}, mockSource, mockDocker).checkForUpdates(context.Background())
+	if err == nil {
+		t.Fatalf("Expected the CI error, but got nil")
+	}
+	if mockDocker.Restarts != 0 {
+		t.Fatalf("Expected no restart, but got %d", mockDocker.Restarts)
+	}
+}
+
 // TestCheckForUpdates_DockerRestartFailure tests the scenario where Docker services fail to restart:
 // - A new commit is detected.
 // - The last GitHub action run was successful.
 // - Docker services fail to restart.
 func TestCheckForUpdates_DockerRestartFailure(t *testing.T) {
-	// Mock GitHub API returning a new master commit and successful last run
-	mockGitHub := &github.MockGitHubAPI{
-		OverrideMasterSum:    "new_sha",
+	// Mock GitHub API returning a new branch commit and successful last run
+	mockSource := &source.MockSource{
+		OverrideBranchSum:    "new_sha",
 		OverrideCurrentSum:   "old_sha",
 		OverrideCheckLastRun: true, // Simulate that the last run succeeded
 		FileDifferences:      []string{"test1", "test2"},
@@ -97,8 +122,290 @@ func TestCheckForUpdates_DockerRestartFailure(t *testing.T) {
 
 	// Call the function under test
 	ctx := context.Background()
-	err := checkForUpdates(ctx, mockGitHub, mockDocker)
+	err := newWorker(env.Project{}, mockSource, mockDocker).checkForUpdates(ctx)
 	if err == nil {
 		t.Fatalf("Expected error due to Docker restart failure, but got nil")
 	}
 }
+
+// TestCheckForUpdates_Branch tests that the configured BRANCH is passed through to the GitHub API:
+// - BRANCH is set to main.
+// - The pull is made against main rather than master.
+func TestCheckForUpdates_Branch(t *testing.T) {
+	os.Setenv("BRANCH", "main")
+	defer os.Unsetenv("BRANCH")
+
+	mockSource := &source.MockSource{
+		OverrideBranchSum:    "new_sha",
+		OverrideCurrentSum:   "old_sha",
+		OverrideCheckLastRun: true,
+		FileDifferences:      []string{"test1"},
+	}
+	mockDocker := &docker.MockDockerManager{}
+
+	w := newWorker(env.Project{}, mockSource, mockDocker)
+	ctx := context.Background()
+	if err := w.checkForUpdates(ctx); err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if mockSource.LastBranch != "main" {
+		t.Fatalf("Expected branch main to be pulled, but got %s", mockSource.LastBranch)
+	}
+}
+
+// TestCheckForUpdates_Release tests that release mode checks out the newest release tag:
+// - RELEASE_MODE is set to tags.
+// - The release commit differs from the current one and its run passed.
+// - The release tag is checked out instead of pulling a branch.
+func TestCheckForUpdates_Release(t *testing.T) {
+	os.Setenv("RELEASE_MODE", "tags")
+	defer os.Unsetenv("RELEASE_MODE")
+
+	mockSource := &source.MockSource{
+		OverrideRelease:      source.Release{Tag: "v1.4.2", Sha: "new_sha"},
+		OverrideCurrentSum:   "old_sha",
+		OverrideCheckLastRun: true,
+		FileDifferences:      []string{"test1"},
+	}
+	mockDocker := &docker.MockDockerManager{}
+
+	w := newWorker(env.Project{}, mockSource, mockDocker)
+	ctx := context.Background()
+	if err := w.checkForUpdates(ctx); err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if mockSource.CheckedOutTag != "v1.4.2" {
+		t.Fatalf("Expected tag v1.4.2 to be checked out, but got %q", mockSource.CheckedOutTag)
+	}
+}
+
+// TestCheckForUpdates_GreenFallback tests falling back to the newest green commit:
+// - FALLBACK_TO_GREEN is enabled.
+// - The tip and the commit before it failed, an earlier commit passed.
+// - The checkout is fast-forwarded to the green commit instead of pulled.
+func TestCheckForUpdates_GreenFallback(t *testing.T) {
+	os.Setenv("FALLBACK_TO_GREEN", "true")
+	defer os.Unsetenv("FALLBACK_TO_GREEN")
+
+	mockSource := &source.MockSource{
+		OverrideBranchSum:  "sha4",
+		OverrideCurrentSum: "sha0",
+		Commits:            []string{"sha1", "sha2", "sha3", "sha4"},
+		GreenShas:          []string{"sha1", "sha2"},
+		FileDifferences:    []string{"test1"},
+	}
+	mockDocker := &docker.MockDockerManager{}
+
+	w := newWorker(env.Project{}, mockSource, mockDocker)
+	ctx := context.Background()
+	if err := w.checkForUpdates(ctx); err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if mockSource.FastForwardedSha != "sha2" {
+		t.Fatalf("Expected fast-forward to sha2, but got %q", mockSource.FastForwardedSha)
+	}
+
+	// Without the fallback nothing is deployed
+	os.Unsetenv("FALLBACK_TO_GREEN")
+	mockSource.FastForwardedSha = ""
+	if err := w.checkForUpdates(ctx); err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if mockSource.FastForwardedSha != "" {
+		t.Fatalf("Expected no fast-forward, but got %q", mockSource.FastForwardedSha)
+	}
+}
+
+// TestCheckForUpdates_GreenFallbackResumeFails tests that a failing restart
+// of a fallback deploy is reported while the tip stays red:
+// - sha2 is deployed instead of the red sha4, but its restart fails.
+// - The next check resumes it, which fails again, and finds no newer green commit.
+func TestCheckForUpdates_GreenFallbackResumeFails(t *testing.T) {
+	defer useDeployStateFile(t)()
+	os.Setenv("FALLBACK_TO_GREEN", "true")
+	defer os.Unsetenv("FALLBACK_TO_GREEN")
+
+	mockSource := &source.MockSource{
+		OverrideBranchSum:  "sha4",
+		OverrideCurrentSum: "sha0",
+		Commits:            []string{"sha1", "sha2", "sha3", "sha4"},
+		GreenShas:          []string{"sha1", "sha2"},
+		FileDifferences:    []string{"test1"},
+	}
+	mockDocker := &docker.MockDockerManager{ShouldFail: true}
+
+	w := newWorker(env.Project{}, mockSource, mockDocker)
+	ctx := context.Background()
+	if err := w.checkForUpdates(ctx); err == nil {
+		t.Fatalf("Expected error due to Docker restart failure, but got nil")
+	}
+
+	// sha2 is checked out now, and sha3 isn't green either
+	mockSource.OverrideCurrentSum = "sha2"
+	mockSource.Commits = []string{"sha3", "sha4"}
+	if err := w.checkForUpdates(ctx); err == nil {
+		t.Fatalf("Expected the resumed restart's error, but got nil")
+	}
+	if mockDocker.Restarts != 2 {
+		t.Fatalf("Expected the restart to be resumed, but got %d restarts", mockDocker.Restarts)
+	}
+	if state := w.loadDeployState(); state == nil || state.Sha != "sha2" || state.Attempts != 2 {
+		t.Fatalf("Expected the second failed attempt of sha2 to be recorded, got %+v", state)
+	}
+}
+
+// TestCheckForUpdates_OnlyDocsChanged tests that non-runtime changes skip the restart:
+// - DEPLOY_EXCLUDE ignores markdown and docs files.
+// - Only such files changed, so the checkout is updated but nothing restarts.
+func TestCheckForUpdates_OnlyDocsChanged(t *testing.T) {
+	os.Setenv("DEPLOY_EXCLUDE", "docs/**,*.md")
+	defer os.Unsetenv("DEPLOY_EXCLUDE")
+
+	mockSource := &source.MockSource{
+		OverrideBranchSum:    "new_sha",
+		OverrideCurrentSum:   "old_sha",
+		OverrideCheckLastRun: true,
+		FileDifferences:      []string{"README.md", "docs/setup.txt"},
+	}
+	// A restart would fail, so an error means services were restarted
+	mockDocker := &docker.MockDockerManager{ShouldFail: true}
+
+	w := newWorker(env.Project{}, mockSource, mockDocker)
+	ctx := context.Background()
+	if err := w.checkForUpdates(ctx); err != nil {
+		t.Fatalf("Expected no restart, but got: %v", err)
+	}
+
+	// A runtime file changing restarts services
+	mockSource.FileDifferences = append(mockSource.FileDifferences, "cmd/main.go")
+	if err := w.checkForUpdates(ctx); err == nil {
+		t.Fatalf("Expected services to be restarted")
+	}
+}
+
+// TestCheckForUpdates_TargetedRestart tests that only affected services are restarted:
+// - DOCKER_SERVICE_PATHS maps backend/ to the api service.
+// - Only backend files changed, so only api is restarted.
+func TestCheckForUpdates_TargetedRestart(t *testing.T) {
+	os.Setenv("DOCKER_SERVICE_PATHS", "api=backend/**;web=frontend/**")
+	defer os.Unsetenv("DOCKER_SERVICE_PATHS")
+
+	mockSource := &source.MockSource{
+		OverrideBranchSum:    "new_sha",
+		OverrideCurrentSum:   "old_sha",
+		OverrideCheckLastRun: true,
+		FileDifferences:      []string{"backend/main.go"},
+	}
+	mockDocker := &docker.MockDockerManager{}
+
+	w := newWorker(env.Project{}, mockSource, mockDocker)
+	ctx := context.Background()
+	if err := w.checkForUpdates(ctx); err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if len(mockDocker.RestartedServices) != 1 || mockDocker.RestartedServices[0] != "api" {
+		t.Fatalf("Expected only api to be restarted, but got %v", mockDocker.RestartedServices)
+	}
+
+	// An unmapped file restarts everything
+	mockSource.FileDifferences = []string{"backend/main.go", "docker-compose.yml"}
+	if err := w.checkForUpdates(ctx); err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if mockDocker.RestartedServices != nil {
+		t.Fatalf("Expected all services to be restarted, but got %v", mockDocker.RestartedServices)
+	}
+}
+
+// TestCheckForUpdates_ProjectServices tests that a project's own service
+// paths replace DOCKER_SERVICE_PATHS, which names another stack's services.
+func TestCheckForUpdates_ProjectServices(t *testing.T) {
+	os.Setenv("DOCKER_SERVICE_PATHS", "api=backend/**")
+	defer os.Unsetenv("DOCKER_SERVICE_PATHS")
+
+	mockSource := &source.MockSource{
+		OverrideBranchSum:    "new_sha",
+		OverrideCurrentSum:   "old_sha",
+		OverrideCheckLastRun: true,
+		FileDifferences:      []string{"backend/main.go"},
+	}
+	mockDocker := &docker.MockDockerManager{}
+	project := env.Project{Docker: env.ProjectDockerConfig{ServicePaths: map[string][]string{"worker": {"backend/**"}}}}
+
+	w := newWorker(project, mockSource, mockDocker)
+	if err := w.checkForUpdates(context.Background()); err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if len(mockDocker.RestartedServices) != 1 || mockDocker.RestartedServices[0] != "worker" {
+		t.Fatalf("Expected only worker to be restarted, but got %v", mockDocker.RestartedServices)
+	}
+}
+
+// TestNewSource tests that SOURCE selects the backend and unknown backends are rejected.
+func TestNewSource(t *testing.T) {
+	defer os.Unsetenv("SOURCE")
+
+	os.Setenv("SOURCE", "gitlab")
+	if src, err := newSource(env.Project{}); err != nil || src == nil {
+		t.Fatalf("Expected a GitLab source, but got %v (%v)", src, err)
+	}
+
+	os.Setenv("SOURCE", "forgejo")
+	if src, err := newSource(env.Project{}); err != nil || src == nil {
+		t.Fatalf("Expected a Gitea source, but got %v (%v)", src, err)
+	}
+
+	os.Setenv("SOURCE", "bitbucket-server")
+	if src, err := newSource(env.Project{}); err != nil || src == nil {
+		t.Fatalf("Expected a Bitbucket Server source, but got %v (%v)", src, err)
+	}
+
+	os.Setenv("SOURCE", "git")
+	if src, err := newSource(env.Project{}); err != nil || src == nil {
+		t.Fatalf("Expected a plain git source, but got %v (%v)", src, err)
+	}
+
+	os.Setenv("SOURCE", "svn")
+	if _, err := newSource(env.Project{}); err == nil {
+		t.Fatalf("Expected an error for an unknown source, but got nil")
+	}
+}
+
+// TestCheckForUpdates_Project tests that a listed project uses its own settings:
+// - BRANCH is main, but the project tracks production.
+// - Its log lines are prefixed with the project name.
+func TestCheckForUpdates_Project(t *testing.T) {
+	defer useDeployStateFile(t)()
+
+	os.Setenv("BRANCH", "main")
+	defer os.Unsetenv("BRANCH")
+
+	repoDir, err := ioutil.TempDir("", "project")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(repoDir)
+	os.MkdirAll(filepath.Join(repoDir, ".git"), 0755)
+
+	mockSource := &source.MockSource{
+		OverrideBranchSum:    "new_sha",
+		OverrideCurrentSum:   "old_sha",
+		OverrideCheckLastRun: true,
+		FileDifferences:      []string{"test1"},
+	}
+	mockDocker := &docker.MockDockerManager{}
+	w := newWorker(env.Project{Name: "app", Branch: "production", RepoDir: repoDir}, mockSource, mockDocker)
+
+	var buf bytes.Buffer
+	w.log.SetOutput(&buf)
+
+	if err := w.checkForUpdates(context.Background()); err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if mockSource.LastBranch != "production" {
+		t.Fatalf("Expected branch production to be pulled, but got %s", mockSource.LastBranch)
+	}
+	if !strings.Contains(buf.String(), "[app] Differences found") {
+		t.Fatalf("Expected log lines prefixed with [app], got '%s'", buf.String())
+	}
+}
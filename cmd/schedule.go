@@ -0,0 +1,47 @@
+package main
+
+import (
+	"math/rand"
+	"time"
+)
+
+// pollSchedule decides how long a worker waits between checks.
+type pollSchedule struct {
+	interval   time.Duration
+	jitter     time.Duration
+	maxBackoff time.Duration
+	random     func(n int64) int64
+}
+
+// newPollSchedule creates a schedule polling every interval seconds, plus up
+// to jitter seconds, and backing off to at most maxBackoff seconds.
+func newPollSchedule(interval, jitter, maxBackoff int) pollSchedule {
+	return pollSchedule{
+		interval:   time.Duration(interval) * time.Second,
+		jitter:     time.Duration(jitter) * time.Second,
+		maxBackoff: time.Duration(maxBackoff) * time.Second,
+		random:     rand.Int63n,
+	}
+}
+
+// next returns the wait before the next check, given how many checks in a row
+// have failed. Each failure doubles the interval, up to maxBackoff (or the
+// interval itself, if longer), and a random jitter is added on top.
+func (s pollSchedule) next(failures int) time.Duration {
+	wait := s.interval
+	limit := s.maxBackoff
+	if limit < s.interval {
+		limit = s.interval
+	}
+	for i := 0; i < failures && wait < limit; i++ {
+		wait *= 2
+	}
+	if wait > limit {
+		wait = limit
+	}
+
+	if s.jitter > 0 {
+		wait += time.Duration(s.random(int64(s.jitter) + 1))
+	}
+	return wait
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestPollSchedule tests the wait after a number of consecutive failures.
+func TestPollSchedule(t *testing.T) {
+	schedule := newPollSchedule(60, 0, 300)
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{failures: 0, want: time.Minute},
+		{failures: 1, want: 2 * time.Minute},
+		{failures: 2, want: 4 * time.Minute},
+		{failures: 3, want: 5 * time.Minute},
+		{failures: 50, want: 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := schedule.next(tt.failures); got != tt.want {
+			t.Errorf("After %d failures: expected %v, got %v", tt.failures, tt.want, got)
+		}
+	}
+}
+
+// TestPollSchedule_IntervalAboveMaxBackoff tests that backing off never
+// shortens the interval.
+func TestPollSchedule_IntervalAboveMaxBackoff(t *testing.T) {
+	schedule := newPollSchedule(900, 0, 300)
+	if got := schedule.next(2); got != 15*time.Minute {
+		t.Fatalf("Expected %v, got %v", 15*time.Minute, got)
+	}
+}
+
+// TestPollSchedule_Jitter tests that the jitter is added within its bound.
+func TestPollSchedule_Jitter(t *testing.T) {
+	schedule := newPollSchedule(60, 10, 300)
+
+	var bound int64
+	schedule.random = func(n int64) int64 {
+		bound = n
+		return n - 1
+	}
+	if got := schedule.next(0); got != 70*time.Second {
+		t.Fatalf("Expected %v, got %v", 70*time.Second, got)
+	}
+	if bound != int64(10*time.Second)+1 {
+		t.Fatalf("Expected jitter of up to 10s, got a bound of %v", time.Duration(bound))
+	}
+}
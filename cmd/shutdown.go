@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// watchSignals handles SIGTERM and SIGINT with shutdownOnSignal.
+func watchSignals(grace time.Duration) (context.Context, <-chan struct{}) {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	return shutdownOnSignal(signals, grace)
+}
+
+// shutdownOnSignal returns a context for checks and a channel closed on the
+// first signal, which ends the wait between checks. The context outlives the
+// signal by the grace period so a deploy in progress can finish, and is
+// cancelled when the period runs out or on a second signal.
+func shutdownOnSignal(signals <-chan os.Signal, grace time.Duration) (context.Context, <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stopping := make(chan struct{})
+	go func() {
+		sig := <-signals
+		log.Printf("Received %v, stopping after the current check (up to %v)", sig, grace)
+		close(stopping)
+
+		select {
+		case <-time.After(grace):
+			log.Println("Shutdown grace period is over, cancelling the current check")
+		case sig = <-signals:
+			log.Printf("Received %v again, cancelling the current check", sig)
+		}
+		cancel()
+	}()
+	return ctx, stopping
+}
@@ -18,6 +18,8 @@ WorkingDirectory={{.WorkingDir}}
 ExecStart={{.ExecPath}}
 Restart=always
 RestartSec=10
+KillMode=mixed
+TimeoutStopSec=360
 User={{.User}}
 
 [Install]
@@ -89,4 +89,7 @@ func TestGenerateSystemdService(t *testing.T) {
 	if !contains(content, "root") {
 		t.Errorf("Expected 'User=root' in the service file, but not found")
 	}
+	if !contains(content, "KillMode=mixed") {
+		t.Errorf("Expected 'KillMode=mixed' in the service file, so deploys in progress only see the signal through autopuller")
+	}
 }
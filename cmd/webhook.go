@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxWebhookPayload is the largest payload accepted, matching GitHub's own cap.
+const maxWebhookPayload = 25 << 20
+
+// Limits of the webhook listener, which may face the internet, so slow or
+// oversized requests can't hold its connections.
+const (
+	webhookReadHeaderTimeout = 10 * time.Second
+	webhookReadTimeout       = 60 * time.Second // Enough for a full payload
+	webhookWriteTimeout      = 30 * time.Second
+	webhookIdleTimeout       = 120 * time.Second
+	webhookMaxHeaderBytes    = 64 << 10
+	webhookShutdownTimeout   = 5 * time.Second
+)
+
+// webhookHandler accepts GitHub push and workflow_run webhooks and requests an
+// immediate update check from each project whose tracked branch (or any tag
+// in release mode) the event concerns. The check itself runs in the
+// project's worker, so the handler only signals its trigger channel.
+type webhookHandler struct {
+	secret  []byte
+	targets []webhookTarget
+}
+
+// webhookTarget is a project the webhook handler triggers checks for.
+type webhookTarget struct {
+	repo     string
+	branch   string
+	releases bool
+	trigger  chan<- struct{}
+
+	// completed is told the commit of a completed workflow run, if set
+	completed func(sha string)
+}
+
+// ServeHTTP validates the X-Hub-Signature-256 HMAC of the payload and handles
+// the event named by X-GitHub-Event.
+func (h *webhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookPayload))
+	if err != nil {
+		http.Error(w, "could not read payload", http.StatusBadRequest)
+		return
+	}
+	if !validSignature(h.secret, body, r.Header.Get("X-Hub-Signature-256")) {
+		log.Printf("Rejected webhook from %s: invalid signature", r.RemoteAddr)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	event := r.Header.Get("X-GitHub-Event")
+	var payload webhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	triggered := false
+	for _, target := range h.targets {
+		if !target.relevant(event, payload) {
+			continue
+		}
+		triggered = true
+		if event == "workflow_run" && target.completed != nil && payload.WorkflowRun.HeadSha != "" {
+			target.completed(payload.WorkflowRun.HeadSha)
+		}
+		select {
+		case target.trigger <- struct{}{}:
+		default:
+			// A check is already queued and will see this change too
+		}
+	}
+	if !triggered {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	log.Printf("Received %s webhook for %s, checking for updates", event, payload.Repository.FullName)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// webhookPayload holds the fields of push and workflow_run payloads that
+// decide which projects are checked.
+type webhookPayload struct {
+	Ref         string `json:"ref"`
+	Action      string `json:"action"`
+	WorkflowRun struct {
+		HeadBranch string `json:"head_branch"`
+		HeadSha    string `json:"head_sha"`
+	} `json:"workflow_run"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+// relevant reports whether the event should trigger an update check of the
+// project: a push to its branch or a tag, or a completed workflow run for
+// either, in its repository.
+func (t webhookTarget) relevant(event string, payload webhookPayload) bool {
+	if payload.Repository.FullName != "" && !strings.EqualFold(payload.Repository.FullName, t.repo) {
+		return false
+	}
+
+	switch event {
+	case "push":
+		if t.releases {
+			return strings.HasPrefix(payload.Ref, "refs/tags/")
+		}
+		return payload.Ref == "refs/heads/"+t.branch
+
+	case "workflow_run":
+		// For tag pushes head_branch is the tag name
+		return payload.Action == "completed" && (t.releases || payload.WorkflowRun.HeadBranch == t.branch)
+	}
+	// ping and any other events are acknowledged but ignored
+	return false
+}
+
+// validSignature checks a "sha256=<hex>" signature of the body against the secret.
+func validSignature(secret, body []byte, signature string) bool {
+	if !strings.HasPrefix(signature, "sha256=") {
+		return false
+	}
+	got, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// newWebhookServer creates a server for the webhook handler at /webhook on
+// addr, with timeouts and a header size limit.
+func newWebhookServer(addr string, handler http.Handler) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/webhook", handler)
+	return &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: webhookReadHeaderTimeout,
+		ReadTimeout:       webhookReadTimeout,
+		WriteTimeout:      webhookWriteTimeout,
+		IdleTimeout:       webhookIdleTimeout,
+		MaxHeaderBytes:    webhookMaxHeaderBytes,
+	}
+}
+
+// startWebhookServer serves the webhook handler at /webhook on addr in the
+// background until stopping is closed.
+func startWebhookServer(addr string, handler http.Handler, stopping <-chan struct{}) {
+	server := newWebhookServer(addr, handler)
+	go func() {
+		log.Printf("Listening for webhooks on %s/webhook", addr)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Webhook listener failed: %v", err)
+		}
+	}()
+	go shutdownWebhookServer(server, stopping)
+}
+
+// shutdownWebhookServer stops the server once stopping is closed, giving
+// requests in flight a few seconds to finish.
+func shutdownWebhookServer(server *http.Server, stopping <-chan struct{}) {
+	<-stopping
+	ctx, cancel := context.WithTimeout(context.Background(), webhookShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Webhook listener did not shut down cleanly: %v", err)
+	}
+}
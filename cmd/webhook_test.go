@@ -0,0 +1,179 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sign returns the X-Hub-Signature-256 value of the body.
+func sign(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// TestWebhookHandler tests which deliveries are accepted and trigger a check.
+func TestWebhookHandler(t *testing.T) {
+	tests := []struct {
+		name      string
+		method    string
+		event     string
+		body      string
+		signature string
+		releases  bool
+		status    int
+		triggered bool
+	}{
+		{name: "push to branch", event: "push", body: `{"ref": "refs/heads/main"}`, status: http.StatusAccepted, triggered: true},
+		{name: "push to repository", event: "push", body: `{"ref": "refs/heads/main", "repository": {"full_name": "Owner/App"}}`, status: http.StatusAccepted, triggered: true},
+		{name: "push to other repository", event: "push", body: `{"ref": "refs/heads/main", "repository": {"full_name": "owner/other"}}`, status: http.StatusNoContent},
+		{name: "push to other branch", event: "push", body: `{"ref": "refs/heads/feature"}`, status: http.StatusNoContent},
+		{name: "tag push in release mode", event: "push", body: `{"ref": "refs/tags/v1.2.0"}`, releases: true, status: http.StatusAccepted, triggered: true},
+		{name: "workflow completed", event: "workflow_run", body: `{"action": "completed", "workflow_run": {"head_branch": "main"}}`, status: http.StatusAccepted, triggered: true},
+		{name: "workflow in progress", event: "workflow_run", body: `{"action": "in_progress", "workflow_run": {"head_branch": "main"}}`, status: http.StatusNoContent},
+		{name: "ping", event: "ping", body: `{"zen": "Keep it logically awesome."}`, status: http.StatusNoContent},
+		{name: "bad signature", event: "push", body: `{"ref": "refs/heads/main"}`, signature: "sha256=00", status: http.StatusUnauthorized},
+		{name: "missing signature", event: "push", body: `{"ref": "refs/heads/main"}`, signature: "none", status: http.StatusUnauthorized},
+		{name: "invalid payload", event: "push", body: `{"ref":`, status: http.StatusBadRequest},
+		{name: "GET", method: "GET", status: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trigger := make(chan struct{}, 1)
+			handler := &webhookHandler{secret: []byte("s3cret"), targets: []webhookTarget{
+				{repo: "owner/app", branch: "main", releases: tt.releases, trigger: trigger},
+			}}
+
+			method := tt.method
+			if method == "" {
+				method = "POST"
+			}
+			req := httptest.NewRequest(method, "/webhook", strings.NewReader(tt.body))
+			req.Header.Set("X-GitHub-Event", tt.event)
+			switch tt.signature {
+			case "":
+				req.Header.Set("X-Hub-Signature-256", sign("s3cret", tt.body))
+			case "none":
+			default:
+				req.Header.Set("X-Hub-Signature-256", tt.signature)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d", tt.status, rec.Code)
+			}
+			if triggered := len(trigger) == 1; triggered != tt.triggered {
+				t.Fatalf("Expected triggered to be %v, got %v", tt.triggered, triggered)
+			}
+		})
+	}
+}
+
+// TestWebhookHandler_Coalesces tests that deliveries arriving before the
+// worker gets to them queue a single check.
+func TestWebhookHandler_Coalesces(t *testing.T) {
+	trigger := make(chan struct{}, 1)
+	handler := &webhookHandler{secret: []byte("s3cret"), targets: []webhookTarget{
+		{repo: "owner/app", branch: "main", trigger: trigger},
+	}}
+
+	body := `{"ref": "refs/heads/main"}`
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest("POST", "/webhook", strings.NewReader(body))
+		req.Header.Set("X-GitHub-Event", "push")
+		req.Header.Set("X-Hub-Signature-256", sign("s3cret", body))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusAccepted {
+			t.Fatalf("Expected status %d, got %d", http.StatusAccepted, rec.Code)
+		}
+	}
+	if len(trigger) != 1 {
+		t.Fatalf("Expected one queued check, got %d", len(trigger))
+	}
+}
+
+// TestWebhookHandler_WorkflowRerun tests that a completed workflow run
+// reports its commit, so a re-run of a rejected commit is checked again.
+func TestWebhookHandler_WorkflowRerun(t *testing.T) {
+	var completed []string
+	trigger := make(chan struct{}, 1)
+	handler := &webhookHandler{secret: []byte("s3cret"), targets: []webhookTarget{
+		{repo: "owner/app", branch: "main", trigger: trigger, completed: func(sha string) { completed = append(completed, sha) }},
+	}}
+
+	body := `{"action": "completed", "workflow_run": {"head_branch": "main", "head_sha": "abc123"}}`
+	req := httptest.NewRequest("POST", "/webhook", strings.NewReader(body))
+	req.Header.Set("X-GitHub-Event", "workflow_run")
+	req.Header.Set("X-Hub-Signature-256", sign("s3cret", body))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d", http.StatusAccepted, rec.Code)
+	}
+	if len(completed) != 1 || completed[0] != "abc123" || len(trigger) != 1 {
+		t.Fatalf("Expected abc123 to be reported before a check, got %v and %d queued checks", completed, len(trigger))
+	}
+}
+
+// TestWebhookHandler_Projects tests that a delivery only triggers a check of
+// the project of its repository.
+func TestWebhookHandler_Projects(t *testing.T) {
+	app := make(chan struct{}, 1)
+	api := make(chan struct{}, 1)
+	handler := &webhookHandler{secret: []byte("s3cret"), targets: []webhookTarget{
+		{repo: "owner/app", branch: "main", trigger: app},
+		{repo: "owner/api", branch: "main", trigger: api},
+	}}
+
+	body := `{"ref": "refs/heads/main", "repository": {"full_name": "owner/api"}}`
+	req := httptest.NewRequest("POST", "/webhook", strings.NewReader(body))
+	req.Header.Set("X-GitHub-Event", "push")
+	req.Header.Set("X-Hub-Signature-256", sign("s3cret", body))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d", http.StatusAccepted, rec.Code)
+	}
+	if len(app) != 0 || len(api) != 1 {
+		t.Fatalf("Expected only owner/api to be checked, got %d and %d queued checks", len(app), len(api))
+	}
+}
+
+// TestWebhookServer tests that the listener has timeouts against slow clients
+// and shuts down once stopping is closed.
+func TestWebhookServer(t *testing.T) {
+	server := newWebhookServer("127.0.0.1:0", &webhookHandler{})
+	if server.ReadHeaderTimeout == 0 || server.ReadTimeout == 0 || server.MaxHeaderBytes == 0 {
+		t.Fatalf("Expected read timeouts and a header limit, got %+v", server)
+	}
+
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(listener) }()
+
+	stopping := make(chan struct{})
+	go shutdownWebhookServer(server, stopping)
+	close(stopping)
+
+	select {
+	case err := <-served:
+		if err != http.ErrServerClosed {
+			t.Fatalf("Expected the server to be closed, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected the server to shut down")
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"autopuller/docker"
+	"autopuller/env"
+	"autopuller/source"
+)
+
+// worker checks one project for updates and deploys them. Each project has
+// its own worker, with its own CI gate, deploy state and log prefix, so
+// projects don't hold each other up.
+type worker struct {
+	project env.Project
+	src     source.Source
+	docker  docker.DockerManager
+	gate    *ciGate
+	log     *log.Logger
+	trigger chan struct{}
+
+	// reruns are the commits whose CI completed again since the last check,
+	// guarded by rerunsMu as webhooks report them from another goroutine
+	rerunsMu sync.Mutex
+	reruns   []string
+
+	// carriedFiles are the changed files of a deploy whose restart was given
+	// up on or superseded, restarted along with the next deploy
+	carriedFiles []string
+}
+
+// newWorker creates a worker for the project using the given backends.
+func newWorker(project env.Project, src source.Source, dockerMgr docker.DockerManager) *worker {
+	logger := log.New(log.Writer(), project.LogPrefix(), log.Flags()|log.Lmsgprefix)
+	gate := newCIGate()
+	gate.log = logger
+	return &worker{
+		project: project,
+		src:     src,
+		docker:  dockerMgr,
+		gate:    gate,
+		log:     logger,
+		trigger: make(chan struct{}, 1),
+	}
+}
+
+// ciCompleted records that the CI of sha completed again, such as a re-run of
+// a failed workflow, so the next check queries it instead of skipping a
+// commit the gate rejected earlier.
+func (w *worker) ciCompleted(sha string) {
+	w.rerunsMu.Lock()
+	defer w.rerunsMu.Unlock()
+	w.reruns = append(w.reruns, sha)
+}
+
+// forgetReruns has the gate forget the commits whose CI completed again.
+func (w *worker) forgetReruns() {
+	w.rerunsMu.Lock()
+	reruns := w.reruns
+	w.reruns = nil
+	w.rerunsMu.Unlock()
+	for _, sha := range reruns {
+		w.gate.forget(sha)
+	}
+}
+
+// run checks for updates every interval seconds, or when triggered, until
+// stopping is closed. It only returns early on a fatal error, which needs the
+// configuration fixed before the project can be checked again.
+func (w *worker) run(ctx context.Context, stopping <-chan struct{}, interval int) error {
+	schedule := newPollSchedule(interval, env.GetIntervalJitter(), env.GetIntervalMaxBackoff())
+	failures := 0
+	for {
+		err := w.checkForUpdates(ctx)
+		if err != nil && source.IsFatal(err) {
+			w.log.Printf("Error in checking updates: %v", err)
+			return err
+		}
+		if err != nil {
+			// Keep running; transient errors were already retried
+			failures++
+			w.log.Printf("Error in checking updates (%d in a row, transient: %v): %v", failures, source.IsTransient(err), err)
+		} else {
+			failures = 0
+		}
+
+		// Back off while checks keep failing
+		wait := schedule.next(failures)
+		var rateLimited *source.RateLimitError
+		if errors.As(err, &rateLimited) {
+			// Wait for the limit to reset
+			wait = time.Until(rateLimited.Until)
+		}
+		if failures > 0 {
+			w.log.Printf("Next check in %v", wait.Round(time.Second))
+		}
+		select {
+		case <-stopping:
+			return nil
+		case <-w.trigger:
+		case <-time.After(wait):
+		}
+	}
+}
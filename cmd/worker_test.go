@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"autopuller/docker"
+	"autopuller/env"
+	"autopuller/source"
+)
+
+// checkErrorSource is a mock source whose CI lookups fail with err, counting
+// them on calls.
+type checkErrorSource struct {
+	source.MockSource
+	err   error
+	calls chan struct{}
+}
+
+// CheckLastRun fails with the configured error.
+func (s *checkErrorSource) CheckLastRun(ctx context.Context, sha string, workflows []string) ([]source.CheckResult, []source.CheckResult, error) {
+	s.calls <- struct{}{}
+	return nil, nil, s.err
+}
+
+// newCheckErrorSource returns a source with a new commit whose CI lookups fail with err.
+func newCheckErrorSource(err error) *checkErrorSource {
+	return &checkErrorSource{
+		MockSource: source.MockSource{OverrideBranchSum: "new_sha", OverrideCurrentSum: "old_sha"},
+		err:        err,
+		calls:      make(chan struct{}, 10),
+	}
+}
+
+// TestWorkerRun_CheckUnauthorized tests that rejected credentials on the
+// checks API stop the worker instead of being taken as a failed run.
+func TestWorkerRun_CheckUnauthorized(t *testing.T) {
+	defer useDeployStateFile(t)()
+	src := newCheckErrorSource(&source.StatusError{Code: http.StatusUnauthorized, URL: "checks"})
+	w := newWorker(env.Project{}, src, &docker.MockDockerManager{})
+
+	done := make(chan error, 1)
+	go func() { done <- w.run(context.Background(), make(chan struct{}), 1) }()
+
+	select {
+	case err := <-done:
+		if !source.IsFatal(err) {
+			t.Fatalf("Expected a fatal error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected the worker to stop on rejected credentials")
+	}
+}
+
+// TestWorkerRun_CheckRateLimited tests that a rate limit on the checks API
+// makes the worker wait until the limit resets.
+func TestWorkerRun_CheckRateLimited(t *testing.T) {
+	defer useDeployStateFile(t)()
+	src := newCheckErrorSource(&source.RateLimitError{Until: time.Now().Add(time.Hour)})
+	w := newWorker(env.Project{}, src, &docker.MockDockerManager{})
+	var logs bytes.Buffer
+	w.log = log.New(&logs, "", 0)
+
+	stopping := make(chan struct{})
+	done := make(chan error, 1)
+	go func() { done <- w.run(context.Background(), stopping, 1) }()
+
+	<-src.calls
+	select {
+	case <-src.calls:
+		t.Fatalf("Expected no check before the rate limit resets")
+	case <-time.After(1500 * time.Millisecond):
+	}
+
+	close(stopping)
+	if err := <-done; err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	// The wait is rounded to the second, so it reads 1h0m0s or just under
+	if !strings.Contains(logs.String(), "Next check in 1h0m0s") && !strings.Contains(logs.String(), "Next check in 59m59s") {
+		t.Fatalf("Expected the next check after the rate limit, got:\n%s", logs.String())
+	}
+}
+
+// TestWorker_CIRerun tests that a commit rejected by the gate is deployed once
+// a webhook reports its CI completed again and the re-run passed.
+func TestWorker_CIRerun(t *testing.T) {
+	defer useDeployStateFile(t)()
+
+	mockSource := &source.MockSource{
+		OverrideBranchSum:  "new_sha",
+		OverrideCurrentSum: "old_sha",
+		FileDifferences:    []string{"app.go"},
+	}
+	mockDocker := &docker.MockDockerManager{}
+	w := newWorker(env.Project{}, mockSource, mockDocker)
+
+	ctx := context.Background()
+	if err := w.checkForUpdates(ctx); err != nil || mockDocker.Restarts != 0 {
+		t.Fatalf("Expected the failed commit to be skipped, got %d restarts (%v)", mockDocker.Restarts, err)
+	}
+
+	// The re-run passes, which only a webhook tells the gate about
+	mockSource.GreenShas = []string{"new_sha"}
+	w.ciCompleted("new_sha")
+	if err := w.checkForUpdates(ctx); err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if mockDocker.Restarts != 1 {
+		t.Fatalf("Expected the re-run commit to be deployed, got %d restarts", mockDocker.Restarts)
+	}
+}
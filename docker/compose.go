@@ -1,65 +1,117 @@
 package docker
 
 import (
+	"bytes"
 	"context"
-	"log"
+	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"autopuller/env"
+	"autopuller/source"
 )
 
 // DockerManager is an interface for Docker-related operations.
 type DockerManager interface {
-	RestartServices(ctx context.Context) error
+	RestartServices(ctx context.Context, services []string) error
 }
 
-type RealDockerManager struct{}
+// RealDockerManager runs Docker Compose in the project directory Dir, or the
+// configured dockerdir if it is empty.
+type RealDockerManager struct {
+	Dir string
+}
+
+// DockerDir returns the absolute path of the Docker Compose project.
+func (d *RealDockerManager) DockerDir() string {
+	dir := d.Dir
+	if dir == "" {
+		dir = env.Current().DockerDir
+	}
+	if abs, err := filepath.Abs(dir); err == nil {
+		return abs
+	}
+	return dir
+}
 
 // commandContext is a wrapper around exec.CommandContext, allowing it to be mocked in tests.
 var commandContext = exec.CommandContext
 
-// runCommand executes a shell command and logs the output.
-func runCommand(ctx context.Context, name string, args ...string) error {
+// runCommand executes a shell command inside dir and logs the output.
+func runCommand(ctx context.Context, dir, name string, args ...string) error {
 
 	cmd := commandContext(ctx, name, args...)
+	cmd.Dir = dir
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
 	if err := cmd.Run(); err != nil {
-		log.Printf("Command %s failed: %v", name, err)
+		source.Logger(ctx).Printf("Command %s failed: %v", name, err)
 		return err
 	}
 	return nil
 }
 
-// RestartServices runs `docker-compose build` and `docker-compose up -d`.
-func (d *RealDockerManager) RestartServices(ctx context.Context) error {
-	// Change to the directory where the Docker Compose file is located
-	repoDir := os.Getenv("DOCKERDIR")
-	if err := os.Chdir(repoDir); err != nil {
-		return err
+// RestartServices rebuilds and restarts services. With no services given it
+// runs `docker-compose build`, `start` and `restart` for the whole project,
+// otherwise `docker-compose build <svc...>` and `up -d <svc...>`.
+func (d *RealDockerManager) RestartServices(ctx context.Context, services []string) error {
+	for _, service := range services {
+		if !serviceNamePattern.MatchString(service) {
+			return fmt.Errorf("invalid service name %q", service)
+		}
 	}
 
-	dockercommand := os.Getenv("DOCKERCOMMAND")
-	if dockercommand == "" {
-		dockercommand = "docker-compose"
+	// Run in the directory where the Docker Compose file is located
+	dir := d.DockerDir()
+
+	dockercommand := env.Current().Docker.Command
+
+	if len(services) > 0 {
+		targets := strings.Join(services, " ")
+		source.Logger(ctx).Printf("Running %s build %s...\n", dockercommand, targets)
+		if err := runCommand(ctx, dir, "bash", "-c", dockercommand+" build "+targets); err != nil {
+			return err
+		}
+
+		source.Logger(ctx).Printf("Running %s up -d %s...\n", dockercommand, targets)
+		return runCommand(ctx, dir, "bash", "-c", dockercommand+" up -d "+targets)
 	}
 
-	log.Printf("Running %s build...\n", dockercommand)
+	source.Logger(ctx).Printf("Running %s build...\n", dockercommand)
 
 	// Load in if there's a docker override
 
-	if err := runCommand(ctx, "bash", "-c", dockercommand+" build"); err != nil {
+	if err := runCommand(ctx, dir, "bash", "-c", dockercommand+" build"); err != nil {
 		return err
 	}
 
-	log.Printf("Running %s start...\n", dockercommand)
-	if err := runCommand(ctx, "bash", "-c", dockercommand+" start"); err != nil {
+	source.Logger(ctx).Printf("Running %s start...\n", dockercommand)
+	if err := runCommand(ctx, dir, "bash", "-c", dockercommand+" start"); err != nil {
 		return err
 	}
-	log.Printf("Running %s restart\n", dockercommand)
+	source.Logger(ctx).Printf("Running %s restart\n", dockercommand)
 
-	if err := runCommand(ctx, "bash", "-c", dockercommand+" restart"); err != nil {
+	if err := runCommand(ctx, dir, "bash", "-c", dockercommand+" restart"); err != nil {
 		return err
 	}
 	return nil
 }
+
+// CheckCompose confirms the Docker Compose command works in the project
+// directory by listing the services of the project.
+func (d *RealDockerManager) CheckCompose(ctx context.Context) ([]string, error) {
+	dockercommand := env.Current().Docker.Command
+
+	cmd := commandContext(ctx, "bash", "-c", dockercommand+" config --services")
+	cmd.Dir = d.DockerDir()
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s config failed in %s: %v: %s", dockercommand, cmd.Dir, err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.Fields(string(output)), nil
+}
@@ -3,6 +3,7 @@ package docker
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"log"
 	"os"
 	"os/exec"
@@ -56,7 +57,7 @@ func TestRestartServices_Success(t *testing.T) {
 	dockerMgr := &RealDockerManager{}
 
 	// Call RestartServices and check if it succeeds
-	err := dockerMgr.RestartServices(context.Background())
+	err := dockerMgr.RestartServices(context.Background(), nil)
 	if err != nil {
 		t.Fatalf("Expected no error, but got: %v", err)
 	}
@@ -83,8 +84,68 @@ func TestRestartServices_Failure(t *testing.T) {
 	dockerMgr := &RealDockerManager{}
 
 	// Call RestartServices and check if it fails
-	err := dockerMgr.RestartServices(context.Background())
+	err := dockerMgr.RestartServices(context.Background(), nil)
 	if err == nil {
 		t.Fatal("Expected an error, but got none")
 	}
 }
+
+// TestRestartServices_Targeted tests that only the given services are rebuilt and brought up.
+func TestRestartServices_Targeted(t *testing.T) {
+	var commands []string
+	commandContext = func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		commands = append(commands, args[len(args)-1])
+		return mockCommandContext(ctx, name, args...)
+	}
+
+	os.Setenv("DOCKERDIR", ".")
+
+	dockerMgr := &RealDockerManager{}
+	if err := dockerMgr.RestartServices(context.Background(), []string{"api", "web"}); err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+
+	expected := []string{"docker-compose build api web", "docker-compose up -d api web"}
+	if len(commands) != len(expected) || commands[0] != expected[0] || commands[1] != expected[1] {
+		t.Fatalf("Expected commands %v, but got %v", expected, commands)
+	}
+
+	// Service names are passed to the shell, so they must be plain names
+	if err := dockerMgr.RestartServices(context.Background(), []string{"api; rm -rf /"}); err == nil {
+		t.Fatal("Expected an error for an invalid service name, but got none")
+	}
+}
+
+// TestHelperProcessServices simulates `docker-compose config --services`.
+func TestHelperProcessServices(*testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	fmt.Println("api")
+	fmt.Println("web")
+	os.Exit(0)
+}
+
+// TestCheckCompose tests that the services of the project are listed.
+func TestCheckCompose(t *testing.T) {
+	var command string
+	commandContext = func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		command = args[len(args)-1]
+		cs := []string{"-test.run=TestHelperProcessServices", "--", name}
+		cs = append(cs, args...)
+		cmd := exec.CommandContext(ctx, os.Args[0], cs...)
+		cmd.Env = []string{"GO_WANT_HELPER_PROCESS=1"}
+		return cmd
+	}
+
+	services, err := (&RealDockerManager{Dir: "."}).CheckCompose(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if command != "docker-compose config --services" {
+		t.Fatalf("Expected 'docker-compose config --services', but got '%s'", command)
+	}
+	if len(services) != 2 || services[0] != "api" || services[1] != "web" {
+		t.Fatalf("Expected services [api web], but got %v", services)
+	}
+}
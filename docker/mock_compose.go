@@ -9,10 +9,19 @@ import (
 type MockDockerManager struct {
 	// ShouldFail allows us to control whether the mock should simulate a failure when restarting services.
 	ShouldFail bool
+
+	// RestartedServices records the services passed to the most recent RestartServices call
+	RestartedServices []string
+	// Restarts counts the calls to RestartServices
+	Restarts int
+	// Services is what CheckCompose lists when it doesn't fail
+	Services []string
 }
 
 // RestartServices simulates restarting Docker services.
-func (m *MockDockerManager) RestartServices(ctx context.Context) error {
+func (m *MockDockerManager) RestartServices(ctx context.Context, services []string) error {
+	m.RestartedServices = services
+	m.Restarts++
 	if m.ShouldFail {
 		// Simulate a failure
 		return errors.New("failed to restart services")
@@ -20,3 +29,11 @@ func (m *MockDockerManager) RestartServices(ctx context.Context) error {
 	// Simulate a successful service restart
 	return nil
 }
+
+// CheckCompose simulates listing the services of the project.
+func (m *MockDockerManager) CheckCompose(ctx context.Context) ([]string, error) {
+	if m.ShouldFail {
+		return nil, errors.New("failed to run docker compose")
+	}
+	return m.Services, nil
+}
@@ -0,0 +1,115 @@
+package docker
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"autopuller/pathfilter"
+
+	"gopkg.in/yaml.v3"
+)
+
+// composeFileNames are the file names docker compose looks for, in order.
+var composeFileNames = []string{"compose.yaml", "compose.yml", "docker-compose.yaml", "docker-compose.yml"}
+
+// serviceNamePattern matches valid compose service names.
+var serviceNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// ServiceContexts reads the compose file in dockerDir and returns, for each
+// service with a build section, a glob matching its build context relative to
+// repoDir. Contexts outside repoDir are skipped.
+func ServiceContexts(dockerDir, repoDir string) (map[string][]string, error) {
+	var data []byte
+	var err error
+	for _, name := range composeFileNames {
+		data, err = ioutil.ReadFile(filepath.Join(dockerDir, name))
+		if !os.IsNotExist(err) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read compose file in %s: %v", dockerDir, err)
+	}
+
+	var compose struct {
+		Services map[string]struct {
+			Build interface{} `yaml:"build"`
+		} `yaml:"services"`
+	}
+	if err := yaml.Unmarshal(data, &compose); err != nil {
+		return nil, fmt.Errorf("could not parse compose file in %s: %v", dockerDir, err)
+	}
+
+	absRepo, err := filepath.Abs(repoDir)
+	if err != nil {
+		return nil, err
+	}
+	contexts := map[string][]string{}
+	for name, service := range compose.Services {
+		// build is either the context path or a mapping with a context key
+		var context string
+		switch build := service.Build.(type) {
+		case string:
+			context = build
+		case map[string]interface{}:
+			context, _ = build["context"].(string)
+			if context == "" {
+				context = "."
+			}
+		default:
+			continue
+		}
+
+		if !filepath.IsAbs(context) {
+			context = filepath.Join(dockerDir, context)
+		}
+		context, err = filepath.Abs(context)
+		if err != nil {
+			return nil, err
+		}
+		rel, err := filepath.Rel(absRepo, context)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if rel == "." {
+			contexts[name] = []string{"**"}
+		} else {
+			contexts[name] = []string{filepath.ToSlash(rel) + "/**"}
+		}
+	}
+	return contexts, nil
+}
+
+// AffectedServices maps changed files to the services whose globs match them.
+// It returns false if a file matches no service, since then it can't be told
+// which services are affected and the whole project should be restarted.
+func AffectedServices(files []string, servicePaths map[string][]string) ([]string, bool) {
+	affected := map[string]bool{}
+	for _, file := range files {
+		matched := false
+		for service, globs := range servicePaths {
+			for _, glob := range globs {
+				if pathfilter.MatchGlob(glob, file) {
+					affected[service] = true
+					matched = true
+					break
+				}
+			}
+		}
+		if !matched {
+			return nil, false
+		}
+	}
+
+	var services []string
+	for service := range affected {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+	return services, true
+}
@@ -0,0 +1,81 @@
+package docker
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestServiceContexts tests deriving service paths from build contexts in the compose file.
+func TestServiceContexts(t *testing.T) {
+	repoDir, err := ioutil.TempDir("", "repo")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(repoDir)
+
+	// The compose file lives in deploy/, with contexts relative to it
+	dockerDir := filepath.Join(repoDir, "deploy")
+	os.MkdirAll(dockerDir, 0755)
+	compose := `services:
+  api:
+    build: ../backend
+  web:
+    build:
+      context: ../frontend
+      dockerfile: Dockerfile.prod
+  tools:
+    build:
+      dockerfile: Dockerfile.tools
+  outside:
+    build: ../../elsewhere
+  db:
+    image: postgres
+`
+	if err := ioutil.WriteFile(filepath.Join(dockerDir, "docker-compose.yml"), []byte(compose), 0644); err != nil {
+		t.Fatalf("Failed to write compose file: %v", err)
+	}
+
+	contexts, err := ServiceContexts(dockerDir, repoDir)
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	expected := map[string][]string{
+		"api":   {"backend/**"},
+		"web":   {"frontend/**"},
+		"tools": {"deploy/**"},
+	}
+	if !reflect.DeepEqual(contexts, expected) {
+		t.Fatalf("Expected %v, but got %v", expected, contexts)
+	}
+
+	// A directory without a compose file is an error
+	if _, err := ServiceContexts(repoDir, repoDir); err == nil {
+		t.Fatal("Expected an error for a missing compose file, but got none")
+	}
+}
+
+// TestAffectedServices tests mapping changed files to services.
+func TestAffectedServices(t *testing.T) {
+	servicePaths := map[string][]string{
+		"api": {"backend/**", "shared/**"},
+		"web": {"frontend/**", "shared/**"},
+	}
+
+	services, ok := AffectedServices([]string{"backend/main.go"}, servicePaths)
+	if !ok || !reflect.DeepEqual(services, []string{"api"}) {
+		t.Fatalf("Expected [api], but got %v (%v)", services, ok)
+	}
+
+	services, ok = AffectedServices([]string{"shared/util.go", "frontend/app.js"}, servicePaths)
+	if !ok || !reflect.DeepEqual(services, []string{"api", "web"}) {
+		t.Fatalf("Expected [api web], but got %v (%v)", services, ok)
+	}
+
+	// A file outside every service can't be targeted
+	if _, ok := AffectedServices([]string{"backend/main.go", "docker-compose.yml"}, servicePaths); ok {
+		t.Fatal("Expected an unmapped file to require a full restart")
+	}
+}
@@ -0,0 +1,434 @@
+package env
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the typed configuration of autopuller. It starts from the
+// defaults, is read from the YAML file in CONFIG_FILE if there is one, and
+// each environment variable that is set overrides the matching file key.
+type Config struct {
+	Source              string `yaml:"source"`
+	Repo                string `yaml:"repo"`
+	Branch              string `yaml:"branch"`
+	RepoDir             string `yaml:"repodir"`
+	DockerDir           string `yaml:"dockerdir"`
+	Interval            int    `yaml:"interval"`
+	IntervalJitter      int    `yaml:"interval_jitter"`
+	IntervalMaxBackoff  int    `yaml:"interval_max_backoff"`
+	FallbackToGreen     bool   `yaml:"fallback_to_green"`
+	ShutdownGracePeriod int    `yaml:"shutdown_grace_period"`
+	CABundle            string `yaml:"ca_bundle"`
+	ProjectsFile        string `yaml:"projects_file"`
+
+	Release   ReleaseConfig   `yaml:"release"`
+	CI        CIConfig        `yaml:"ci"`
+	Deploy    DeployConfig    `yaml:"deploy"`
+	Docker    DockerConfig    `yaml:"docker"`
+	Webhook   WebhookConfig   `yaml:"webhook"`
+	GitHub    GitHubConfig    `yaml:"github"`
+	GitLab    GitLabConfig    `yaml:"gitlab"`
+	Gitea     GiteaConfig     `yaml:"gitea"`
+	Bitbucket BitbucketConfig `yaml:"bitbucket"`
+	Git       GitConfig       `yaml:"git"`
+	Projects  []Project       `yaml:"projects"`
+}
+
+// ReleaseConfig selects tagged releases instead of the branch tip.
+type ReleaseConfig struct {
+	Mode       string `yaml:"mode"`
+	Constraint string `yaml:"constraint"`
+	Prerelease bool   `yaml:"prerelease"`
+}
+
+// CIConfig decides which CI results gate a deploy.
+type CIConfig struct {
+	Policy            string   `yaml:"policy"`
+	RequiredChecks    []string `yaml:"required_checks"`
+	OptionalChecks    []string `yaml:"optional_checks"`
+	RequiredWorkflows []string `yaml:"required_workflows"`
+	MaxWait           int      `yaml:"max_wait"`
+	NoChecks          string   `yaml:"no_checks"`
+}
+
+// DeployConfig decides which changed files restart services, and where a
+// deploy in progress is recorded.
+type DeployConfig struct {
+	Include   []string `yaml:"include"`
+	Exclude   []string `yaml:"exclude"`
+	StateFile string   `yaml:"state_file"`
+}
+
+// DockerConfig configures how Docker Compose is run.
+type DockerConfig struct {
+	Command             string              `yaml:"command"`
+	ServicePaths        map[string][]string `yaml:"service_paths"`
+	ServicesFromCompose bool                `yaml:"services_from_compose"`
+}
+
+// WebhookConfig configures the webhook listener.
+type WebhookConfig struct {
+	Listen       string `yaml:"listen"`
+	Secret       string `yaml:"secret"`
+	PollInterval int    `yaml:"poll_interval"`
+}
+
+// GitHubConfig holds the GitHub credentials and API location.
+type GitHubConfig struct {
+	Key               string `yaml:"key"`
+	AppID             string `yaml:"app_id"`
+	AppPrivateKey     string `yaml:"app_private_key"`
+	AppInstallationID string `yaml:"app_installation_id"`
+	APIURL            string `yaml:"api_url"`
+	URLPrefix         string `yaml:"url_prefix"`
+}
+
+// GitLabConfig holds the GitLab instance and API token.
+type GitLabConfig struct {
+	URL string `yaml:"url"`
+	Key string `yaml:"key"`
+}
+
+// GiteaConfig holds the Gitea or Forgejo instance and API token.
+type GiteaConfig struct {
+	URL string `yaml:"url"`
+	Key string `yaml:"key"`
+}
+
+// BitbucketConfig holds the Bitbucket credentials, and the instance for
+// Bitbucket Server.
+type BitbucketConfig struct {
+	URL  string `yaml:"url"`
+	User string `yaml:"user"`
+	Key  string `yaml:"key"`
+}
+
+// GitConfig decides how the plain git backend gates deploys.
+type GitConfig struct {
+	CIGate    string `yaml:"ci_gate"`
+	CIMarker  string `yaml:"ci_marker"`
+	CICommand string `yaml:"ci_command"`
+}
+
+// defaultConfig returns the configuration used for every key that is
+// neither in the config file nor in the environment.
+func defaultConfig() Config {
+	return Config{
+		Source:              "github",
+		Branch:              "master",
+		Interval:            60,
+		IntervalMaxBackoff:  900, // 15 minutes
+		ShutdownGracePeriod: 300, // 5 minutes, enough for most image builds
+		CI:                  CIConfig{Policy: "all", MaxWait: 3600, NoChecks: "pending"},
+		Docker:              DockerConfig{Command: "docker-compose"},
+		Webhook:             WebhookConfig{PollInterval: 900}, // 15 minutes as a safety net
+		GitLab:              GitLabConfig{URL: "https://gitlab.com"},
+		Git:                 GitConfig{CIGate: "none"},
+	}
+}
+
+// setting ties a config key to the environment variable overriding it.
+type setting struct {
+	key   string                      // Config file key
+	env   string                      // Environment variable
+	field func(c *Config) interface{} // Pointer to the Config field
+	min   int                         // Smallest value allowed for numbers
+	oneOf []string                    // Values allowed for strings, if limited
+}
+
+// settings lists every key that can be overridden from the environment.
+var settings = []setting{
+	{key: "source", env: "SOURCE", field: func(c *Config) interface{} { return &c.Source },
+		oneOf: []string{"github", "gitlab", "gitea", "forgejo", "bitbucket", "bitbucket-server", "git"}},
+	{key: "repo", env: "REPONAME", field: func(c *Config) interface{} { return &c.Repo }},
+	{key: "branch", env: "BRANCH", field: func(c *Config) interface{} { return &c.Branch }},
+	{key: "repodir", env: "REPODIR", field: func(c *Config) interface{} { return &c.RepoDir }},
+	{key: "dockerdir", env: "DOCKERDIR", field: func(c *Config) interface{} { return &c.DockerDir }},
+	{key: "interval", env: "INTERVAL", field: func(c *Config) interface{} { return &c.Interval }, min: 1},
+	{key: "interval_jitter", env: "INTERVAL_JITTER", field: func(c *Config) interface{} { return &c.IntervalJitter }},
+	{key: "interval_max_backoff", env: "INTERVAL_MAX_BACKOFF", field: func(c *Config) interface{} { return &c.IntervalMaxBackoff }},
+	{key: "fallback_to_green", env: "FALLBACK_TO_GREEN", field: func(c *Config) interface{} { return &c.FallbackToGreen }},
+	{key: "shutdown_grace_period", env: "SHUTDOWN_GRACE_PERIOD", field: func(c *Config) interface{} { return &c.ShutdownGracePeriod }},
+	{key: "ca_bundle", env: "CA_BUNDLE", field: func(c *Config) interface{} { return &c.CABundle }},
+	{key: "projects_file", env: "PROJECTS_FILE", field: func(c *Config) interface{} { return &c.ProjectsFile }},
+
+	{key: "release.mode", env: "RELEASE_MODE", field: func(c *Config) interface{} { return &c.Release.Mode },
+		oneOf: []string{"", "tags", "releases"}},
+	{key: "release.constraint", env: "RELEASE_CONSTRAINT", field: func(c *Config) interface{} { return &c.Release.Constraint }},
+	{key: "release.prerelease", env: "RELEASE_PRERELEASE", field: func(c *Config) interface{} { return &c.Release.Prerelease }},
+
+	{key: "ci.policy", env: "CI_POLICY", field: func(c *Config) interface{} { return &c.CI.Policy },
+		oneOf: []string{"all", "required"}},
+	{key: "ci.required_checks", env: "CI_REQUIRED_CHECKS", field: func(c *Config) interface{} { return &c.CI.RequiredChecks }},
+	{key: "ci.optional_checks", env: "CI_OPTIONAL_CHECKS", field: func(c *Config) interface{} { return &c.CI.OptionalChecks }},
+	{key: "ci.required_workflows", env: "CI_REQUIRED_WORKFLOWS", field: func(c *Config) interface{} { return &c.CI.RequiredWorkflows }},
+	{key: "ci.max_wait", env: "CI_MAX_WAIT", field: func(c *Config) interface{} { return &c.CI.MaxWait }},
+	{key: "ci.no_checks", env: "CI_NO_CHECKS", field: func(c *Config) interface{} { return &c.CI.NoChecks },
+		oneOf: []string{"pending", "pass"}},
+
+	{key: "deploy.include", env: "DEPLOY_INCLUDE", field: func(c *Config) interface{} { return &c.Deploy.Include }},
+	{key: "deploy.exclude", env: "DEPLOY_EXCLUDE", field: func(c *Config) interface{} { return &c.Deploy.Exclude }},
+	{key: "deploy.state_file", env: "DEPLOY_STATE_FILE", field: func(c *Config) interface{} { return &c.Deploy.StateFile }},
+
+	{key: "docker.command", env: "DOCKERCOMMAND", field: func(c *Config) interface{} { return &c.Docker.Command }},
+	{key: "docker.service_paths", env: "DOCKER_SERVICE_PATHS", field: func(c *Config) interface{} { return &c.Docker.ServicePaths }},
+	{key: "docker.services_from_compose", env: "DOCKER_SERVICES_FROM_COMPOSE", field: func(c *Config) interface{} { return &c.Docker.ServicesFromCompose }},
+
+	{key: "webhook.listen", env: "WEBHOOK_LISTEN", field: func(c *Config) interface{} { return &c.Webhook.Listen }},
+	{key: "webhook.secret", env: "WEBHOOK_SECRET", field: func(c *Config) interface{} { return &c.Webhook.Secret }},
+	{key: "webhook.poll_interval", env: "WEBHOOK_POLL_INTERVAL", field: func(c *Config) interface{} { return &c.Webhook.PollInterval }, min: 1},
+
+	{key: "github.key", env: "GITHUBKEY", field: func(c *Config) interface{} { return &c.GitHub.Key }},
+	{key: "github.app_id", env: "GITHUB_APP_ID", field: func(c *Config) interface{} { return &c.GitHub.AppID }},
+	{key: "github.app_private_key", env: "GITHUB_APP_PRIVATE_KEY", field: func(c *Config) interface{} { return &c.GitHub.AppPrivateKey }},
+	{key: "github.app_installation_id", env: "GITHUB_APP_INSTALLATION_ID", field: func(c *Config) interface{} { return &c.GitHub.AppInstallationID }},
+	{key: "github.api_url", env: "GITHUB_API_URL", field: func(c *Config) interface{} { return &c.GitHub.APIURL }},
+	{key: "github.url_prefix", env: "GITHUB_URL_PREFIX", field: func(c *Config) interface{} { return &c.GitHub.URLPrefix }},
+
+	{key: "gitlab.url", env: "GITLAB_URL", field: func(c *Config) interface{} { return &c.GitLab.URL }},
+	{key: "gitlab.key", env: "GITLABKEY", field: func(c *Config) interface{} { return &c.GitLab.Key }},
+
+	{key: "gitea.url", env: "GITEA_URL", field: func(c *Config) interface{} { return &c.Gitea.URL }},
+	{key: "gitea.key", env: "GITEAKEY", field: func(c *Config) interface{} { return &c.Gitea.Key }},
+
+	{key: "bitbucket.url", env: "BITBUCKET_URL", field: func(c *Config) interface{} { return &c.Bitbucket.URL }},
+	{key: "bitbucket.user", env: "BITBUCKET_USER", field: func(c *Config) interface{} { return &c.Bitbucket.User }},
+	{key: "bitbucket.key", env: "BITBUCKETKEY", field: func(c *Config) interface{} { return &c.Bitbucket.Key }},
+
+	{key: "git.ci_gate", env: "GIT_CI_GATE", field: func(c *Config) interface{} { return &c.Git.CIGate },
+		oneOf: []string{"none", "marker", "command"}},
+	{key: "git.ci_marker", env: "GIT_CI_MARKER", field: func(c *Config) interface{} { return &c.Git.CIMarker }},
+	{key: "git.ci_command", env: "GIT_CI_COMMAND", field: func(c *Config) interface{} { return &c.Git.CICommand }},
+}
+
+// name names the setting in error messages by both its key and variable.
+func (s setting) name() string {
+	return s.key + " (" + s.env + ")"
+}
+
+// set parses an environment value into the setting's field of c. Numbers
+// below the minimum are rejected, leaving the field unchanged.
+func (s setting) set(c *Config, value string) error {
+	switch field := s.field(c).(type) {
+	case *string:
+		*field = value
+	case *int:
+		number, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("%s: %q is not a number", s.name(), value)
+		}
+		if number < s.min {
+			return fmt.Errorf("%s: must be at least %d, got %d", s.name(), s.min, number)
+		}
+		*field = number
+	case *bool:
+		flag, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("%s: %q is not true or false", s.name(), value)
+		}
+		*field = flag
+	case *[]string:
+		*field = splitList(value)
+	case *map[string][]string:
+		*field = parseServicePaths(value)
+	}
+	return nil
+}
+
+// check validates the setting's field of c.
+func (s setting) check(c *Config) error {
+	switch field := s.field(c).(type) {
+	case *string:
+		if s.oneOf != nil && !contains(s.oneOf, *field) {
+			return fmt.Errorf("%s: %q is not one of %s", s.name(), *field, strings.Join(s.oneOf, ", "))
+		}
+	case *int:
+		if *field < s.min {
+			return fmt.Errorf("%s: must be at least %d, got %d", s.name(), s.min, *field)
+		}
+	}
+	return nil
+}
+
+// ConfigError lists every problem found in the configuration.
+type ConfigError struct {
+	Problems []string
+}
+
+// Error joins the problems, one per line.
+func (e *ConfigError) Error() string {
+	return "invalid configuration:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+// loaded is the configuration read by LoadConfig, if it was called.
+var loaded *Config
+
+// GetConfigFile gets the YAML config file to read, or empty to configure
+// autopuller from the environment alone.
+func GetConfigFile() string {
+	return os.Getenv("CONFIG_FILE")
+}
+
+// LoadConfig reads the config file in CONFIG_FILE, applies the environment
+// on top of it and validates the result, which Current returns from then on.
+func LoadConfig() (*Config, error) {
+	cfg, err := readConfig(GetConfigFile())
+	if err != nil {
+		return nil, err
+	}
+	loaded = cfg
+	return cfg, nil
+}
+
+// readConfig builds the configuration from the defaults, the file at path
+// (if any) and the environment, reporting every invalid key at once.
+func readConfig(path string) (*Config, error) {
+	cfg := defaultConfig()
+
+	// Values from a .env file sit below the config file, and the real
+	// environment above it
+	problems := applyEnv(&cfg, true)
+	if path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CONFIG_FILE: %v", err)
+		}
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&cfg); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+		}
+	}
+
+	problems = append(problems, applyEnv(&cfg, false)...)
+	for _, s := range settings {
+		if err := s.check(&cfg); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	problems = append(problems, cfg.validate()...)
+	if len(problems) > 0 {
+		return nil, &ConfigError{Problems: problems}
+	}
+	return &cfg, nil
+}
+
+// applyEnv sets each setting whose variable was set by a .env file, if
+// fromDotenv, or in the real environment otherwise. It returns the values
+// that don't parse.
+func applyEnv(cfg *Config, fromDotenv bool) []string {
+	var problems []string
+	for _, s := range settings {
+		if value := os.Getenv(s.env); value != "" && dotenvVars[s.env] == fromDotenv {
+			if err := s.set(cfg, value); err != nil {
+				problems = append(problems, err.Error())
+			}
+		}
+	}
+	return problems
+}
+
+// validate checks the rules that span several keys.
+func (c *Config) validate() []string {
+	var problems []string
+	if c.Source != "git" && c.Repo == "" && len(c.Projects) == 0 {
+		problems = append(problems, "repo (REPONAME): must be set")
+	}
+	if c.Webhook.Listen != "" && c.Webhook.Secret == "" {
+		problems = append(problems, "webhook.secret (WEBHOOK_SECRET): must be set when webhook.listen is")
+	}
+	if c.GitHub.AppID != "" && c.GitHub.AppPrivateKey == "" {
+		problems = append(problems, "github.app_private_key (GITHUB_APP_PRIVATE_KEY): must be set when github.app_id is")
+	}
+	problems = append(problems, c.validateSource()...)
+	if len(c.Projects) > 0 {
+		if err := checkProjects(c.Projects); err != nil {
+			problems = append(problems, "projects: "+err.Error())
+		}
+	}
+	return problems
+}
+
+// validateSource checks that the settings the selected source can't work
+// without are set.
+func (c *Config) validateSource() []string {
+	var problems []string
+	// Workflows are GitHub Actions runs, which no other backend can look up
+	if c.Source != "github" && len(c.CI.RequiredWorkflows) > 0 {
+		problems = append(problems, "ci.required_workflows (CI_REQUIRED_WORKFLOWS): only supported when source is github, got "+c.Source)
+	}
+	switch c.Source {
+	case "gitlab":
+		if c.GitLab.URL == "" {
+			problems = append(problems, "gitlab.url (GITLAB_URL): must be set when source is gitlab")
+		}
+	case "gitea", "forgejo":
+		if c.Gitea.URL == "" {
+			problems = append(problems, "gitea.url (GITEA_URL): must be set when source is "+c.Source)
+		}
+	case "bitbucket", "bitbucket-server":
+		if c.Source == "bitbucket-server" && c.Bitbucket.URL == "" {
+			problems = append(problems, "bitbucket.url (BITBUCKET_URL): must be set when source is bitbucket-server")
+		}
+		if c.Bitbucket.User != "" && c.Bitbucket.Key == "" {
+			problems = append(problems, "bitbucket.key (BITBUCKETKEY): must be set when bitbucket.user is")
+		}
+	case "git":
+		if c.Git.CIGate == "marker" && c.Git.CIMarker == "" {
+			problems = append(problems, "git.ci_marker (GIT_CI_MARKER): must be set when git.ci_gate is marker")
+		}
+		if c.Git.CIGate == "command" && c.Git.CICommand == "" {
+			problems = append(problems, "git.ci_command (GIT_CI_COMMAND): must be set when git.ci_gate is command")
+		}
+	}
+	return problems
+}
+
+// Current returns the configuration read by LoadConfig. Until it has been
+// called, the configuration is built from the environment on every call,
+// with values that don't parse left at their defaults.
+func Current() Config {
+	if loaded != nil {
+		return *loaded
+	}
+	cfg := defaultConfig()
+	for _, s := range settings {
+		if value := os.Getenv(s.env); value != "" {
+			s.set(&cfg, value)
+		}
+	}
+	return cfg
+}
+
+// parseServicePaths parses service path globs written as
+// `service=glob,glob;service=glob`, skipping malformed entries.
+func parseServicePaths(value string) map[string][]string {
+	servicePaths := map[string][]string{}
+	for _, entry := range strings.Split(value, ";") {
+		parts := strings.SplitN(entry, "=", 2)
+		service := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || service == "" {
+			continue
+		}
+		servicePaths[service] = append(servicePaths[service], splitList(parts[1])...)
+	}
+	return servicePaths
+}
+
+// contains reports whether value is one of values.
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
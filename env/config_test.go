@@ -0,0 +1,126 @@
+package env
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+// writeConfig writes a config file and returns its path.
+func writeConfig(t *testing.T, content string) string {
+	file, err := ioutil.TempFile("", "autopuller-*.yaml")
+	if err != nil {
+		t.Fatalf("Failed to create config file: %v", err)
+	}
+	defer file.Close()
+	file.WriteString(content)
+	return file.Name()
+}
+
+func TestReadConfig(t *testing.T) {
+	path := writeConfig(t, `
+repo: owner/app
+interval: 120
+deploy:
+  exclude: ["docs/**", "*.md"]
+docker:
+  command: docker compose
+  service_paths:
+    api: [backend/**, shared/**]
+    web: [frontend/**]
+github:
+  key: file-key
+gitea:
+  url: https://gitea.example.com
+git:
+  ci_gate: marker
+  ci_marker: /var/run/ci/{sha}
+`)
+	defer os.Remove(path)
+
+	// The environment overrides the file
+	os.Setenv("GITHUBKEY", "env-key")
+	defer os.Unsetenv("GITHUBKEY")
+
+	cfg, err := readConfig(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cfg.Repo != "owner/app" || cfg.Interval != 120 || cfg.Docker.Command != "docker compose" {
+		t.Fatalf("Expected the values of the file, got %+v", cfg)
+	}
+	if len(cfg.Deploy.Exclude) != 2 || len(cfg.Docker.ServicePaths["api"]) != 2 {
+		t.Fatalf("Expected the lists of the file, got %+v", cfg)
+	}
+	if cfg.GitHub.Key != "env-key" {
+		t.Fatalf("Expected GITHUBKEY to override the file, got '%s'", cfg.GitHub.Key)
+	}
+	if cfg.Gitea.URL != "https://gitea.example.com" || cfg.Git.CIGate != "marker" || cfg.Git.CIMarker != "/var/run/ci/{sha}" {
+		t.Fatalf("Expected the backend settings of the file, got %+v", cfg)
+	}
+	if cfg.Branch != "master" || cfg.CI.MaxWait != 3600 || cfg.GitLab.URL != "https://gitlab.com" {
+		t.Fatalf("Expected defaults for keys left out, got %+v", cfg)
+	}
+}
+
+func TestReadConfig_Invalid(t *testing.T) {
+	os.Setenv("REPONAME", "owner/app")
+	defer os.Unsetenv("REPONAME")
+
+	tests := []struct {
+		name string
+		yaml string
+		env  map[string]string
+		want string
+	}{
+		{name: "unknown key", yaml: "intervall: 60\n", want: "intervall"},
+		{name: "wrong type", yaml: "interval: soon\n", want: "soon"},
+		{name: "too small", yaml: "interval: 0\n", want: "interval (INTERVAL): must be at least 1"},
+		{name: "not allowed", yaml: "ci:\n  policy: most\n", want: "ci.policy (CI_POLICY)"},
+		{name: "bad environment", env: map[string]string{"WEBHOOK_POLL_INTERVAL": "often"}, want: "webhook.poll_interval (WEBHOOK_POLL_INTERVAL)"},
+		{name: "webhook without secret", yaml: "webhook:\n  listen: \":8080\"\n", want: "webhook.secret (WEBHOOK_SECRET)"},
+		{name: "app without key", yaml: "github:\n  app_id: \"1234\"\n", want: "github.app_private_key"},
+		{name: "gitea without url", yaml: "source: gitea\n", want: "gitea.url (GITEA_URL): must be set"},
+		{name: "bitbucket server without url", env: map[string]string{"SOURCE": "bitbucket-server"}, want: "bitbucket.url (BITBUCKET_URL): must be set"},
+		{name: "bitbucket user without key", yaml: "source: bitbucket\nbitbucket:\n  user: me\n", want: "bitbucket.key (BITBUCKETKEY)"},
+		{name: "unknown git gate", yaml: "git:\n  ci_gate: sometimes\n", want: "git.ci_gate (GIT_CI_GATE)"},
+		{name: "git command gate without command", yaml: "source: git\ngit:\n  ci_gate: command\n", want: "git.ci_command (GIT_CI_COMMAND): must be set"},
+		{name: "workflows without github", yaml: "source: gitlab\nci:\n  required_workflows: [build]\n", want: "ci.required_workflows (CI_REQUIRED_WORKFLOWS): only supported when source is github"},
+		{name: "duplicate project", yaml: "projects:\n  - name: app\n    repodir: /srv/a\n  - name: app\n    repodir: /srv/b\n", want: "projects: project app is listed twice"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeConfig(t, tt.yaml)
+			defer os.Remove(path)
+			for key, value := range tt.env {
+				os.Setenv(key, value)
+				defer os.Unsetenv(key)
+			}
+
+			_, err := readConfig(path)
+			if err == nil {
+				t.Fatalf("Expected an error, but got nil")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Expected the error to mention '%s', got '%v'", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := writeConfig(t, "repo: owner/app\nbranch: production\n")
+	defer os.Remove(path)
+
+	os.Setenv("CONFIG_FILE", path)
+	defer os.Unsetenv("CONFIG_FILE")
+	defer func() { loaded = nil }()
+
+	if _, err := LoadConfig(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if branch := GetBranch(); branch != "production" {
+		t.Fatalf("Expected branch production from the config file, got %s", branch)
+	}
+}
@@ -3,29 +3,192 @@ package env
 import (
 	"log"
 	"os"
-	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
 
-// LoadEnv loads environment variables from a .env file.
+// dotenvVars records the variables LoadEnv set from a .env file, as opposed
+// to those set in the real environment. Only the latter override the config
+// file.
+var dotenvVars = map[string]bool{}
+
+// LoadEnv loads environment variables from a .env file, falling back to
+// .env.sample unless a config file is given. Variables already set in the
+// environment are left alone.
 func LoadEnv() error {
-	if err := godotenv.Load(); err != nil {
+	values, err := godotenv.Read()
+	if err != nil {
+		if GetConfigFile() != "" {
+			return err
+		}
 		log.Println("No .env file found. Loading sample env if available...")
-		if err := godotenv.Load(".env.sample"); err != nil {
+		if values, err = godotenv.Read(".env.sample"); err != nil {
 			log.Println("No .env.sample file found either.")
 			return err
 		}
 	}
+	for key, value := range values {
+		if _, set := os.LookupEnv(key); !set {
+			os.Setenv(key, value)
+			dotenvVars[key] = true
+		}
+	}
 	return nil
 }
 
 // GetInterval gets the interval for sleeping between checks, with a default value.
 func GetInterval() int {
-	intervalStr := os.Getenv("INTERVAL")
-	interval, err := strconv.Atoi(intervalStr)
-	if err != nil {
-		return 60 // Default to 60 seconds if not set
+	return Current().Interval
+}
+
+// GetIntervalJitter gets the most seconds added at random to each interval,
+// so several autopullers don't poll in lockstep. Defaults to none.
+func GetIntervalJitter() int {
+	return Current().IntervalJitter
+}
+
+// GetIntervalMaxBackoff gets the longest interval in seconds that consecutive
+// failures may stretch the interval to, with a default value.
+func GetIntervalMaxBackoff() int {
+	return Current().IntervalMaxBackoff
+}
+
+// GetSource gets the hosting backend of the repository, defaulting to github.
+func GetSource() string {
+	return Current().Source
+}
+
+// GetBranch gets the branch to track, defaulting to master.
+func GetBranch() string {
+	return Current().Branch
+}
+
+// GetGreenFallback reports whether to deploy the newest green commit when the
+// tip of the branch hasn't passed CI.
+func GetGreenFallback() bool {
+	return Current().FallbackToGreen
+}
+
+// GetReleaseMode gets how tagged releases are discovered: "tags", "releases",
+// or empty to follow the branch tip instead.
+func GetReleaseMode() string {
+	return Current().Release.Mode
+}
+
+// GetReleaseConstraint gets the version constraint releases must match (e.g. ~1.4).
+func GetReleaseConstraint() string {
+	return Current().Release.Constraint
+}
+
+// GetReleasePrerelease reports whether prerelease versions may be deployed.
+func GetReleasePrerelease() bool {
+	return Current().Release.Prerelease
+}
+
+// GetCIPolicy gets how CI checks gate a deploy: "all" checks must pass (default)
+// or only the "required" ones.
+func GetCIPolicy() string {
+	return Current().CI.Policy
+}
+
+// GetCIRequiredChecks gets the names of checks that must pass before deploying.
+func GetCIRequiredChecks() []string {
+	return Current().CI.RequiredChecks
+}
+
+// GetCIOptionalChecks gets the names of checks whose result is ignored.
+func GetCIOptionalChecks() []string {
+	return Current().CI.OptionalChecks
+}
+
+// GetCIRequiredWorkflows gets the names or file paths of workflows that must
+// conclude success before deploying.
+func GetCIRequiredWorkflows() []string {
+	return Current().CI.RequiredWorkflows
+}
+
+// GetCIMaxWait gets how many seconds pending CI is waited on before the
+// commit is skipped, with a default value. Zero waits forever.
+func GetCIMaxWait() int {
+	return Current().CI.MaxWait
+}
+
+// GetCINoChecks gets how a commit without any checks is treated: "pending"
+// (default) waits for checks to be reported, "pass" deploys it.
+func GetCINoChecks() string {
+	return Current().CI.NoChecks
+}
+
+// GetDeployInclude gets the globs of changed files that trigger a restart; empty includes every file.
+func GetDeployInclude() []string {
+	return Current().Deploy.Include
+}
+
+// GetDeployExclude gets the globs of changed files that never trigger a restart.
+func GetDeployExclude() []string {
+	return Current().Deploy.Exclude
+}
+
+// GetDockerServicePaths gets the path globs of each compose service, written
+// in DOCKER_SERVICE_PATHS as `service=glob,glob;service=glob`. The map is a
+// copy the caller may add to.
+func GetDockerServicePaths() map[string][]string {
+	servicePaths := map[string][]string{}
+	for service, globs := range Current().Docker.ServicePaths {
+		servicePaths[service] = append([]string(nil), globs...)
+	}
+	return servicePaths
+}
+
+// GetDockerServicesFromCompose reports whether service paths are derived from
+// each service's build context in the compose file.
+func GetDockerServicesFromCompose() bool {
+	return Current().Docker.ServicesFromCompose
+}
+
+// GetWebhookListen gets the address the webhook listener binds to (e.g.
+// :8080), or empty to only poll.
+func GetWebhookListen() string {
+	return Current().Webhook.Listen
+}
+
+// GetWebhookSecret gets the secret webhook payloads are signed with.
+func GetWebhookSecret() string {
+	return Current().Webhook.Secret
+}
+
+// GetWebhookPollInterval gets the interval in seconds between checks while
+// webhooks are enabled, with a default value.
+func GetWebhookPollInterval() int {
+	return Current().Webhook.PollInterval
+}
+
+// GetShutdownGracePeriod gets how many seconds a check in progress may run
+// after SIGTERM or SIGINT before it is cancelled, with a default value.
+func GetShutdownGracePeriod() int {
+	return Current().ShutdownGracePeriod
+}
+
+// GetDeployStateFile gets where an unfinished deploy is recorded, or empty
+// to keep it in the checkout's git directory.
+func GetDeployStateFile() string {
+	return Current().Deploy.StateFile
+}
+
+// GetCABundle gets the path of a PEM file with extra CA certificates to trust
+// for API requests, such as those of an internal GitHub Enterprise Server.
+func GetCABundle() string {
+	return Current().CABundle
+}
+
+// splitList splits a comma-separated value, dropping empty entries.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
 	}
-	return interval
+	return items
 }
@@ -1,7 +1,9 @@
 package env
 
 import (
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -28,4 +30,154 @@ func TestGetInterval(t *testing.T) {
 	if interval != 60 {
 		t.Fatalf("Expected default interval 60 for invalid INTERVAL, but got %d", interval)
 	}
+
+	// Set a non-positive INTERVAL and check if it defaults to 60
+	os.Setenv("INTERVAL", "0")
+	interval = GetInterval()
+	if interval != 60 {
+		t.Fatalf("Expected default interval 60 for INTERVAL=0, but got %d", interval)
+	}
+}
+
+func TestGetBranch(t *testing.T) {
+	// Set BRANCH and check if it's returned
+	os.Setenv("BRANCH", "main")
+	defer os.Unsetenv("BRANCH")
+
+	branch := GetBranch()
+	if branch != "main" {
+		t.Fatalf("Expected branch main, but got %s", branch)
+	}
+
+	// Unset BRANCH and check if it defaults to master
+	os.Unsetenv("BRANCH")
+	branch = GetBranch()
+	if branch != "master" {
+		t.Fatalf("Expected default branch master, but got %s", branch)
+	}
+}
+
+func TestGetReleasePrerelease(t *testing.T) {
+	// Set RELEASE_PRERELEASE and check if it's parsed
+	os.Setenv("RELEASE_PRERELEASE", "true")
+	defer os.Unsetenv("RELEASE_PRERELEASE")
+
+	if !GetReleasePrerelease() {
+		t.Fatalf("Expected prereleases to be allowed")
+	}
+
+	// Set invalid RELEASE_PRERELEASE and check if it defaults to false
+	os.Setenv("RELEASE_PRERELEASE", "invalid")
+	if GetReleasePrerelease() {
+		t.Fatalf("Expected prereleases to be excluded for invalid RELEASE_PRERELEASE")
+	}
+}
+
+func TestGetCIRequiredChecks(t *testing.T) {
+	// Set CI_REQUIRED_CHECKS and check if it's split and trimmed
+	os.Setenv("CI_REQUIRED_CHECKS", "test, build,,lint ")
+	defer os.Unsetenv("CI_REQUIRED_CHECKS")
+
+	checks := GetCIRequiredChecks()
+	if len(checks) != 3 || checks[0] != "test" || checks[1] != "build" || checks[2] != "lint" {
+		t.Fatalf("Expected [test build lint], but got %v", checks)
+	}
+
+	// Unset CI_REQUIRED_CHECKS and check if it's empty
+	os.Unsetenv("CI_REQUIRED_CHECKS")
+	if checks := GetCIRequiredChecks(); len(checks) != 0 {
+		t.Fatalf("Expected no required checks, but got %v", checks)
+	}
+}
+
+func TestGetCIMaxWait(t *testing.T) {
+	// Set CI_MAX_WAIT and check if it's correctly parsed
+	os.Setenv("CI_MAX_WAIT", "0")
+	defer os.Unsetenv("CI_MAX_WAIT")
+
+	if maxWait := GetCIMaxWait(); maxWait != 0 {
+		t.Fatalf("Expected max wait 0, but got %d", maxWait)
+	}
+
+	// Unset CI_MAX_WAIT and check if it defaults to an hour
+	os.Unsetenv("CI_MAX_WAIT")
+	if maxWait := GetCIMaxWait(); maxWait != 3600 {
+		t.Fatalf("Expected default max wait 3600, but got %d", maxWait)
+	}
+}
+
+func TestGetDockerServicePaths(t *testing.T) {
+	// Set DOCKER_SERVICE_PATHS and check if it's parsed
+	os.Setenv("DOCKER_SERVICE_PATHS", "api=backend/**, shared/**; web=frontend/**;broken")
+	defer os.Unsetenv("DOCKER_SERVICE_PATHS")
+
+	servicePaths := GetDockerServicePaths()
+	if len(servicePaths) != 2 || len(servicePaths["api"]) != 2 || servicePaths["web"][0] != "frontend/**" {
+		t.Fatalf("Expected api and web service paths, but got %v", servicePaths)
+	}
+}
+
+// useEnvDir runs the test in a temp dir holding the given .env files, and
+// returns a function that restores the working directory and unsets the
+// variables LoadEnv set.
+func useEnvDir(t *testing.T, files map[string]string) func() {
+	dir, err := ioutil.TempDir("", "autopuller-env")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	for name, content := range files {
+		ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+	}
+	before, _ := os.Getwd()
+	os.Chdir(dir)
+	return func() {
+		os.Chdir(before)
+		os.RemoveAll(dir)
+		for key := range dotenvVars {
+			os.Unsetenv(key)
+		}
+		dotenvVars = map[string]bool{}
+	}
+}
+
+// TestLoadEnv_ConfigFilePrecedence checks that the config file overrides a
+// .env file but not the real environment.
+func TestLoadEnv_ConfigFilePrecedence(t *testing.T) {
+	defer useEnvDir(t, map[string]string{".env": "REPONAME=amunchet/autopuller-go\nBRANCH=master\nINTERVAL=60\nDOCKERDIR=/srv/env\n"})()
+	path := writeConfig(t, "repo: acme/shop\nbranch: main\ninterval: 120\n")
+	defer os.Remove(path)
+	os.Setenv("INTERVAL", "90")
+	defer os.Unsetenv("INTERVAL")
+
+	if err := LoadEnv(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	cfg, err := readConfig(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cfg.Repo != "acme/shop" || cfg.Branch != "main" {
+		t.Fatalf("Expected the config file to override .env, got repo=%s branch=%s", cfg.Repo, cfg.Branch)
+	}
+	if cfg.Interval != 90 {
+		t.Fatalf("Expected INTERVAL from the environment to override the file, got %d", cfg.Interval)
+	}
+	if cfg.DockerDir != "/srv/env" {
+		t.Fatalf("Expected .env to fill in keys the file leaves out, got '%s'", cfg.DockerDir)
+	}
+}
+
+// TestLoadEnv_NoSampleWithConfigFile checks that .env.sample isn't loaded
+// when a config file is given.
+func TestLoadEnv_NoSampleWithConfigFile(t *testing.T) {
+	defer useEnvDir(t, map[string]string{".env.sample": "REPONAME=amunchet/autopuller-go\n"})()
+	os.Setenv("CONFIG_FILE", "autopuller.yaml")
+	defer os.Unsetenv("CONFIG_FILE")
+
+	if err := LoadEnv(); err == nil {
+		t.Fatalf("Expected an error for the missing .env, but got nil")
+	}
+	if repo := os.Getenv("REPONAME"); repo != "" {
+		t.Fatalf("Expected .env.sample to be skipped, got REPONAME=%s", repo)
+	}
 }
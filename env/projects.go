@@ -0,0 +1,206 @@
+package env
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Project is one repository watched by the daemon. Empty fields fall back to
+// the environment variable of the same setting, so a project only lists what
+// differs from the shared configuration.
+type Project struct {
+	Name      string `yaml:"name"`
+	Repo      string `yaml:"repo"`      // REPONAME
+	Branch    string `yaml:"branch"`    // BRANCH
+	RepoDir   string `yaml:"repodir"`   // REPODIR
+	DockerDir string `yaml:"dockerdir"` // DOCKERDIR
+	Interval  int    `yaml:"interval"`  // INTERVAL
+
+	Deploy ProjectDeployConfig `yaml:"deploy"`
+	Docker ProjectDockerConfig `yaml:"docker"`
+}
+
+// ProjectDeployConfig holds the path filters of one project. Lists left out
+// fall back to the top-level deploy section.
+type ProjectDeployConfig struct {
+	Include []string `yaml:"include"` // DEPLOY_INCLUDE
+	Exclude []string `yaml:"exclude"` // DEPLOY_EXCLUDE
+}
+
+// ProjectDockerConfig maps the changed files of one project to its compose
+// services. Keys left out fall back to the top-level docker section.
+type ProjectDockerConfig struct {
+	ServicePaths        map[string][]string `yaml:"service_paths"`         // DOCKER_SERVICE_PATHS
+	ServicesFromCompose *bool               `yaml:"services_from_compose"` // DOCKER_SERVICES_FROM_COMPOSE
+}
+
+// GetProjectsFile gets the YAML file listing the projects to watch, or empty
+// to watch the single project configured by the environment.
+func GetProjectsFile() string {
+	return Current().ProjectsFile
+}
+
+// LoadProjects returns the projects listed in the config file or in
+// PROJECTS_FILE, or the single unnamed project configured by the top-level
+// keys and the environment if neither lists any.
+func LoadProjects() ([]Project, error) {
+	if projects := Current().Projects; len(projects) > 0 {
+		return projects, nil
+	}
+	path := GetProjectsFile()
+	if path == "" {
+		return []Project{{}}, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PROJECTS_FILE: %v", err)
+	}
+	return parseProjects(data)
+}
+
+// parseProjects parses a projects file and checks that every project has a
+// unique name and a checkout of its own.
+func parseProjects(data []byte) ([]Project, error) {
+	var file struct {
+		Projects []Project `yaml:"projects"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse PROJECTS_FILE: %v", err)
+	}
+	if len(file.Projects) == 0 {
+		return nil, fmt.Errorf("PROJECTS_FILE lists no projects")
+	}
+	if err := checkProjects(file.Projects); err != nil {
+		return nil, fmt.Errorf("PROJECTS_FILE: %v", err)
+	}
+	return file.Projects, nil
+}
+
+// checkProjects checks that every project has a unique name and a checkout
+// of its own.
+func checkProjects(projects []Project) error {
+	names := map[string]bool{}
+	dirs := map[string]string{}
+	for i, project := range projects {
+		if project.Name == "" {
+			return fmt.Errorf("project %d has no name", i+1)
+		}
+		if names[project.Name] {
+			return fmt.Errorf("project %s is listed twice", project.Name)
+		}
+		names[project.Name] = true
+
+		dir := project.GetRepoDir()
+		if other, ok := dirs[dir]; ok {
+			return fmt.Errorf("projects %s and %s share the checkout %s", other, project.Name, dir)
+		}
+		dirs[dir] = project.Name
+	}
+	return nil
+}
+
+// GetRepo gets the repository to follow, e.g. owner/name.
+func (p Project) GetRepo() string {
+	if p.Repo != "" {
+		return p.Repo
+	}
+	return Current().Repo
+}
+
+// GetBranch gets the branch to track, defaulting to master.
+func (p Project) GetBranch() string {
+	if p.Branch != "" {
+		return p.Branch
+	}
+	return GetBranch()
+}
+
+// GetRepoDir gets the absolute path of the checkout.
+func (p Project) GetRepoDir() string {
+	return absDir(p.RepoDir, Current().RepoDir)
+}
+
+// GetDockerDir gets the absolute path of the Docker Compose project.
+func (p Project) GetDockerDir() string {
+	return absDir(p.DockerDir, Current().DockerDir)
+}
+
+// GetInterval gets the interval for sleeping between checks.
+func (p Project) GetInterval() int {
+	if p.Interval > 0 {
+		return p.Interval
+	}
+	return GetInterval()
+}
+
+// GetDeployInclude gets the globs of changed files that trigger a restart.
+func (p Project) GetDeployInclude() []string {
+	if p.Deploy.Include != nil {
+		return p.Deploy.Include
+	}
+	return GetDeployInclude()
+}
+
+// GetDeployExclude gets the globs of changed files that never trigger a restart.
+func (p Project) GetDeployExclude() []string {
+	if p.Deploy.Exclude != nil {
+		return p.Deploy.Exclude
+	}
+	return GetDeployExclude()
+}
+
+// GetDockerServicePaths gets the path globs of each of the project's compose
+// services. The map is a copy the caller may change.
+func (p Project) GetDockerServicePaths() map[string][]string {
+	if p.Docker.ServicePaths == nil {
+		return GetDockerServicePaths()
+	}
+	servicePaths := map[string][]string{}
+	for service, globs := range p.Docker.ServicePaths {
+		servicePaths[service] = append([]string(nil), globs...)
+	}
+	return servicePaths
+}
+
+// GetDockerServicesFromCompose reports whether the project's service paths
+// are derived from the build contexts in its compose file.
+func (p Project) GetDockerServicesFromCompose() bool {
+	if p.Docker.ServicesFromCompose != nil {
+		return *p.Docker.ServicesFromCompose
+	}
+	return GetDockerServicesFromCompose()
+}
+
+// GetDeployStateFile gets where an unfinished deploy of the project is
+// recorded, or empty to keep it in the checkout's git directory.
+// DEPLOY_STATE_FILE only applies to the project configured by the top-level
+// keys; listed projects always keep theirs in their own checkout.
+func (p Project) GetDeployStateFile() string {
+	if p.Name == "" {
+		return GetDeployStateFile()
+	}
+	return ""
+}
+
+// LogPrefix gets the prefix of the project's log lines, empty for the
+// project configured by the environment.
+func (p Project) LogPrefix() string {
+	if p.Name == "" {
+		return ""
+	}
+	return "[" + p.Name + "] "
+}
+
+// absDir resolves dir, or fallback if dir is empty, to an absolute path.
+func absDir(dir, fallback string) string {
+	if dir == "" {
+		dir = fallback
+	}
+	if abs, err := filepath.Abs(dir); err == nil {
+		return abs
+	}
+	return dir
+}
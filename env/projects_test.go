@@ -0,0 +1,119 @@
+package env
+
+import (
+	"os"
+	"testing"
+)
+
+func TestParseProjects(t *testing.T) {
+	os.Setenv("REPODIR", "/srv/default")
+	os.Setenv("BRANCH", "main")
+	os.Setenv("INTERVAL", "30")
+	defer os.Unsetenv("REPODIR")
+	defer os.Unsetenv("BRANCH")
+	defer os.Unsetenv("INTERVAL")
+
+	projects, err := parseProjects([]byte(`
+projects:
+  - name: app
+    repo: owner/app
+    repodir: /srv/app
+    dockerdir: /srv/app/deploy
+    branch: production
+    interval: 120
+  - name: api
+    repo: owner/api
+`))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(projects) != 2 {
+		t.Fatalf("Expected 2 projects, got %d", len(projects))
+	}
+
+	app, api := projects[0], projects[1]
+	if app.GetRepo() != "owner/app" || app.GetBranch() != "production" || app.GetInterval() != 120 || app.GetDockerDir() != "/srv/app/deploy" {
+		t.Fatalf("Expected the settings of app, got %+v", app)
+	}
+	if api.GetRepoDir() != "/srv/default" || api.GetBranch() != "main" || api.GetInterval() != 30 {
+		t.Fatalf("Expected api to fall back to the environment, got %+v", api)
+	}
+	if file := app.GetDeployStateFile(); file != "" {
+		t.Fatalf("Expected the deploy state of app in its checkout, got %s", file)
+	}
+	if prefix := app.LogPrefix(); prefix != "[app] " {
+		t.Fatalf("Expected log prefix '[app] ', got '%s'", prefix)
+	}
+}
+
+// TestParseProjects_Deploy checks that path filters and service paths are
+// kept per project, falling back to the top-level settings.
+func TestParseProjects_Deploy(t *testing.T) {
+	os.Setenv("DEPLOY_EXCLUDE", "*.md")
+	os.Setenv("DOCKER_SERVICE_PATHS", "api=backend/**")
+	defer os.Unsetenv("DEPLOY_EXCLUDE")
+	defer os.Unsetenv("DOCKER_SERVICE_PATHS")
+
+	projects, err := parseProjects([]byte(`
+projects:
+  - name: shop
+    repodir: /srv/shop
+    deploy:
+      exclude: ["docs/**"]
+    docker:
+      service_paths:
+        web: [frontend/**]
+      services_from_compose: true
+  - name: blog
+    repodir: /srv/blog
+`))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	shop, blog := projects[0], projects[1]
+	if exclude := shop.GetDeployExclude(); len(exclude) != 1 || exclude[0] != "docs/**" {
+		t.Fatalf("Expected the excludes of shop, got %v", exclude)
+	}
+	if paths := shop.GetDockerServicePaths(); len(paths) != 1 || paths["web"][0] != "frontend/**" || !shop.GetDockerServicesFromCompose() {
+		t.Fatalf("Expected the services of shop, got %v", paths)
+	}
+	if exclude := blog.GetDeployExclude(); len(exclude) != 1 || exclude[0] != "*.md" {
+		t.Fatalf("Expected blog to fall back to DEPLOY_EXCLUDE, got %v", exclude)
+	}
+	if paths := blog.GetDockerServicePaths(); len(paths) != 1 || paths["api"][0] != "backend/**" || blog.GetDockerServicesFromCompose() {
+		t.Fatalf("Expected blog to fall back to DOCKER_SERVICE_PATHS, got %v", paths)
+	}
+}
+
+func TestParseProjects_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+	}{
+		{name: "no projects", yaml: `projects: []`},
+		{name: "missing name", yaml: "projects:\n  - repo: owner/app\n    repodir: /srv/app\n"},
+		{name: "duplicate name", yaml: "projects:\n  - name: app\n    repodir: /srv/a\n  - name: app\n    repodir: /srv/b\n"},
+		{name: "shared checkout", yaml: "projects:\n  - name: app\n    repodir: /srv/app\n  - name: api\n    repodir: /srv/app/\n"},
+		{name: "malformed", yaml: "projects: {"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseProjects([]byte(tt.yaml)); err == nil {
+				t.Fatalf("Expected an error, but got nil")
+			}
+		})
+	}
+}
+
+func TestLoadProjects_Environment(t *testing.T) {
+	os.Unsetenv("PROJECTS_FILE")
+
+	projects, err := LoadProjects()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(projects) != 1 || projects[0].Name != "" {
+		t.Fatalf("Expected the single project of the environment, got %+v", projects)
+	}
+}
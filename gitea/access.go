@@ -0,0 +1,32 @@
+package gitea
+
+import (
+	"context"
+	"fmt"
+
+	"autopuller/env"
+	"autopuller/source"
+)
+
+// CheckAccess confirms the followed repository can be read with GITEAKEY, or
+// without a token if none is set, and describes it.
+func (g *RealGiteaAPI) CheckAccess(ctx context.Context) (string, error) {
+	if g.RepoName() == "" {
+		return "", fmt.Errorf("repo (REPONAME) is not set")
+	}
+
+	var repo struct {
+		Private bool `json:"private"`
+	}
+	if _, err := getJSON(ctx, g.repoURL(""), &repo); err != nil {
+		return "", source.AccessError(g.RepoName(), err)
+	}
+	visibility := "public"
+	if repo.Private {
+		visibility = "private"
+	}
+	if env.Current().Gitea.Key == "" {
+		return fmt.Sprintf("%s is %s and readable without a token", g.RepoName(), visibility), nil
+	}
+	return fmt.Sprintf("%s is %s and readable with the token", g.RepoName(), visibility), nil
+}
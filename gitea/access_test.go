@@ -0,0 +1,30 @@
+package gitea
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// TestCheckAccess checks that a readable repository is described and that a
+// repository hidden from the token is explained.
+func TestCheckAccess(t *testing.T) {
+	ts := newFakeGitea(t, map[string]string{
+		"/api/v1/repos/owner/repo": `{"private": true}`,
+	})
+	defer ts.Close()
+
+	desc, err := (&RealGiteaAPI{}).CheckAccess(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if desc != "owner/repo is private and readable with the token" {
+		t.Fatalf("Expected the repository to be described, got '%s'", desc)
+	}
+
+	gitea := &RealGiteaAPI{}
+	gitea.Repo = "owner/other"
+	if _, err := gitea.CheckAccess(context.Background()); err == nil || !strings.Contains(err.Error(), "owner/other not found or not visible") {
+		t.Fatalf("Expected a hidden repository to be explained, got %v", err)
+	}
+}
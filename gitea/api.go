@@ -0,0 +1,104 @@
+package gitea
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"autopuller/env"
+	"autopuller/source"
+)
+
+// RealGiteaAPI is the Gitea (and Forgejo) implementation of source.Source.
+type RealGiteaAPI struct {
+	source.GitCheckout
+}
+
+// repoURL builds an API URL for a path under the followed repository.
+// The repository name is owner/repo and GITEA_URL the instance.
+func (g *RealGiteaAPI) repoURL(path string) string {
+	return strings.TrimSuffix(env.Current().Gitea.URL, "/") + "/api/v1/repos/" + g.RepoName() + path
+}
+
+// getJSON performs an authenticated GET request and decodes the JSON response
+// into result. It returns the URL of the next page, if any.
+func getJSON(ctx context.Context, url string, result interface{}) (string, error) {
+	header := http.Header{}
+	if giteakey := env.Current().Gitea.Key; giteakey != "" {
+		header.Set("Authorization", "token "+giteakey)
+	}
+	return source.GetJSON(ctx, url, header, result)
+}
+
+// GetBranchSum fetches the latest commit SHA from Gitea for the given branch.
+func (g *RealGiteaAPI) GetBranchSum(ctx context.Context, branch string) (string, error) {
+	var result struct {
+		Commit struct {
+			ID string `json:"id"`
+		} `json:"commit"`
+	}
+	if _, err := getJSON(ctx, g.repoURL("/branches/"+url.PathEscape(branch)), &result); err != nil {
+		return "", err
+	}
+	return result.Commit.ID, nil
+}
+
+// compareCommit is a commit of a compare response with the files it touched.
+type compareCommit struct {
+	Sha     string `json:"sha"`
+	Parents []struct {
+		Sha string `json:"sha"`
+	} `json:"parents"`
+	Files []struct {
+		Filename string `json:"filename"`
+	} `json:"files"`
+}
+
+// compare lists the commits between two SHAs, newest first as git log does.
+func (g *RealGiteaAPI) compare(ctx context.Context, oldSha, newSha string) ([]compareCommit, error) {
+	var result struct {
+		Commits []compareCommit `json:"commits"`
+	}
+	_, err := getJSON(ctx, g.repoURL(fmt.Sprintf("/compare/%s...%s", oldSha, newSha)), &result)
+	return result.Commits, err
+}
+
+// CheckDifferences compares two SHAs and returns a list of changed files,
+// gathered from the files of every commit in between.
+func (g *RealGiteaAPI) CheckDifferences(ctx context.Context, oldSha, newSha string) ([]string, error) {
+	commits, err := g.compare(ctx, oldSha, newSha)
+	if err != nil {
+		return nil, err
+	}
+
+	var diffs []string
+	seen := map[string]bool{}
+	for _, commit := range commits {
+		for _, file := range commit.Files {
+			if !seen[file.Filename] {
+				seen[file.Filename] = true
+				diffs = append(diffs, file.Filename)
+			}
+		}
+	}
+	return diffs, nil
+}
+
+// ListCommits lists the SHAs of the commits after oldSha up to and including
+// newSha on the first-parent line of newSha, oldest first.
+func (g *RealGiteaAPI) ListCommits(ctx context.Context, oldSha, newSha string) ([]string, error) {
+	commits, err := g.compare(ctx, oldSha, newSha)
+	if err != nil {
+		return nil, err
+	}
+	parents := map[string][]string{}
+	for _, commit := range commits {
+		parents[commit.Sha] = nil
+		for _, parent := range commit.Parents {
+			parents[commit.Sha] = append(parents[commit.Sha], parent.Sha)
+		}
+	}
+	return source.FirstParentLine(newSha, parents), nil
+}
@@ -0,0 +1,141 @@
+package gitea
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"autopuller/source"
+)
+
+// newFakeGitea starts an httptest stand-in for the Gitea API serving the
+// given responses by path, answering 404 to anything else, and points the
+// backend at it.
+func newFakeGitea(t *testing.T, responses map[string]string) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token fake-key" {
+			t.Errorf("Expected the Authorization header to be set")
+		}
+		body, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+
+	os.Setenv("GITEA_URL", ts.URL)
+	os.Setenv("GITEAKEY", "fake-key")
+	os.Setenv("REPONAME", "owner/repo")
+	return ts
+}
+
+func TestGetBranchSum_Success(t *testing.T) {
+	ts := newFakeGitea(t, map[string]string{
+		"/api/v1/repos/owner/repo/branches/main": `{"name": "main", "commit": {"id": "fake-sha"}}`,
+	})
+	defer ts.Close()
+
+	gitea := &RealGiteaAPI{}
+	sha, err := gitea.GetBranchSum(context.Background(), "main")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if sha != "fake-sha" {
+		t.Fatalf("Expected SHA 'fake-sha', got '%s'", sha)
+	}
+}
+
+func TestGetBranchSum_Failure(t *testing.T) {
+	ts := newFakeGitea(t, map[string]string{})
+	defer ts.Close()
+
+	gitea := &RealGiteaAPI{}
+	if _, err := gitea.GetBranchSum(context.Background(), "main"); err == nil {
+		t.Fatalf("Expected an error, but got nil")
+	}
+}
+
+func TestCheckDifferences_Success(t *testing.T) {
+	ts := newFakeGitea(t, map[string]string{
+		"/api/v1/repos/owner/repo/compare/oldSha...newSha": `{
+			"total_commits": 2,
+			"commits": [
+				{"sha": "newSha", "parents": [{"sha": "sha1"}, {"sha": "side"}], "files": [{"filename": "file2.txt"}, {"filename": "file1.txt"}]},
+				{"sha": "side", "parents": [{"sha": "oldSha"}]},
+				{"sha": "sha1", "parents": [{"sha": "oldSha"}], "files": [{"filename": "file1.txt"}]}
+			]
+		}`,
+	})
+	defer ts.Close()
+
+	gitea := &RealGiteaAPI{}
+	diffs, err := gitea.CheckDifferences(context.Background(), "oldSha", "newSha")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(diffs) != 2 || diffs[0] != "file2.txt" || diffs[1] != "file1.txt" {
+		t.Fatalf("Expected [file2.txt file1.txt], got %v", diffs)
+	}
+
+	commits, err := gitea.ListCommits(context.Background(), "oldSha", "newSha")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(commits) != 2 || commits[0] != "sha1" || commits[1] != "newSha" {
+		t.Fatalf("Expected [sha1 newSha], got %v", commits)
+	}
+}
+
+func TestCheckLastRun(t *testing.T) {
+	ts := newFakeGitea(t, map[string]string{
+		"/api/v1/repos/owner/repo/commits/fake-sha/status": `{
+			"state": "failure",
+			"statuses": [
+				{"context": "ci / test (push)", "status": "success"},
+				{"context": "ci / lint (push)", "status": "failure"}
+			]
+		}`,
+	})
+	defer ts.Close()
+
+	gitea := &RealGiteaAPI{}
+
+	checks, _, err := gitea.CheckLastRun(context.Background(), "fake-sha", nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(checks) != 2 || checks[0].State != source.CIPassed || checks[1].State != source.CIFailed {
+		t.Fatalf("Expected the test job to pass and the lint job to fail, got %v", checks)
+	}
+}
+
+func TestLatestRelease_Releases(t *testing.T) {
+	ts := newFakeGitea(t, map[string]string{
+		"/api/v1/repos/owner/repo/tags": `[
+			{"name": "v2.0.0", "commit": {"sha": "sha-200"}},
+			{"name": "v2.1.0", "commit": {"sha": "sha-210"}}
+		]`,
+		"/api/v1/repos/owner/repo/releases": `[
+			{"tag_name": "v2.1.0", "prerelease": true},
+			{"tag_name": "v2.0.0"}
+		]`,
+	})
+	defer ts.Close()
+
+	os.Setenv("RELEASE_MODE", "releases")
+	defer os.Unsetenv("RELEASE_MODE")
+
+	gitea := &RealGiteaAPI{}
+	release, err := source.LatestRelease(context.Background(), gitea, "", false)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if release.Tag != "v2.0.0" || release.Sha != "sha-200" {
+		t.Fatalf("Expected v2.0.0 (sha-200), got %s (%s)", release.Tag, release.Sha)
+	}
+}
@@ -0,0 +1,59 @@
+package gitea
+
+import (
+	"context"
+
+	"autopuller/env"
+	"autopuller/source"
+)
+
+// ListReleases lists the published Gitea releases with RELEASE_MODE=releases,
+// and every tag in the repository otherwise.
+func (g *RealGiteaAPI) ListReleases(ctx context.Context) ([]source.ReleaseCandidate, error) {
+	// Tags carry the commit, so they are listed in both modes
+	tagShas := map[string]string{}
+	var candidates []source.ReleaseCandidate
+	url := g.repoURL("/tags?limit=50")
+	for url != "" {
+		var page []struct {
+			Name   string `json:"name"`
+			Commit struct {
+				Sha string `json:"sha"`
+			} `json:"commit"`
+		}
+		next, err := getJSON(ctx, url, &page)
+		if err != nil {
+			return nil, err
+		}
+		for _, tag := range page {
+			tagShas[tag.Name] = tag.Commit.Sha
+			candidates = append(candidates, source.ReleaseCandidate{Tag: tag.Name, Sha: tag.Commit.Sha})
+		}
+		url = next
+	}
+
+	if env.GetReleaseMode() == "releases" {
+		candidates = nil
+		url = g.repoURL("/releases?draft=false&limit=50")
+		for url != "" {
+			var page []struct {
+				TagName    string `json:"tag_name"`
+				Draft      bool   `json:"draft"`
+				Prerelease bool   `json:"prerelease"`
+			}
+			next, err := getJSON(ctx, url, &page)
+			if err != nil {
+				return nil, err
+			}
+			for _, release := range page {
+				if release.Draft {
+					continue
+				}
+				candidates = append(candidates, source.ReleaseCandidate{Tag: release.TagName, Sha: tagShas[release.TagName], Prerelease: release.Prerelease})
+			}
+			url = next
+		}
+	}
+
+	return candidates, nil
+}
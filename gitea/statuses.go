@@ -0,0 +1,43 @@
+package gitea
+
+import (
+	"context"
+
+	"autopuller/source"
+)
+
+// CheckLastRun gathers the combined commit status of the commit. Gitea Actions report each job as a status context, so required
+// checks name those contexts.
+func (g *RealGiteaAPI) CheckLastRun(ctx context.Context, sha string, workflows []string) ([]source.CheckResult, []source.CheckResult, error) {
+	var checks []source.CheckResult
+	url := g.repoURL("/commits/" + sha + "/status?limit=50")
+	for url != "" {
+		var page struct {
+			Statuses []struct {
+				Context string `json:"context"`
+				Status  string `json:"status"`
+			} `json:"statuses"`
+		}
+		next, err := getJSON(ctx, url, &page)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, status := range page.Statuses {
+			checks = append(checks, source.CheckResult{Name: status.Context, State: statusState(status.Status)})
+		}
+		url = next
+	}
+
+	return checks, nil, nil
+}
+
+// statusState maps a Gitea commit status to a CIState.
+func statusState(status string) source.CIState {
+	switch status {
+	case "success", "skipped":
+		return source.CIPassed
+	case "pending":
+		return source.CIPending
+	}
+	return source.CIFailed
+}
@@ -0,0 +1,83 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"autopuller/env"
+	"autopuller/source"
+)
+
+// CheckAccess confirms the followed repository can be read with the
+// configured credentials and describes them. A classic personal access token
+// lists its scopes in X-OAuth-Scopes, so one without the repo scope is
+// rejected for a private repository even though it may still read metadata.
+func (g *RealGitHubAPI) CheckAccess(ctx context.Context) (string, error) {
+	if g.RepoName() == "" {
+		return "", fmt.Errorf("repo (REPONAME) is not set")
+	}
+
+	url := g.repoURL("")
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req.WithContext(withRepo(ctx, g.RepoName())))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		return "", fmt.Errorf("the token was rejected: %v", &source.StatusError{Code: resp.StatusCode, URL: url})
+	case http.StatusNotFound:
+		return "", fmt.Errorf("repository %s not found or not visible to the token: %v", g.RepoName(), &source.StatusError{Code: resp.StatusCode, URL: url})
+	default:
+		return "", &source.StatusError{Code: resp.StatusCode, URL: url}
+	}
+
+	var repo struct {
+		Private bool `json:"private"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&repo); err != nil {
+		return "", err
+	}
+
+	cfg := env.Current().GitHub
+	switch {
+	case cfg.AppID != "":
+		return fmt.Sprintf("%s is readable by GitHub App %s", g.RepoName(), cfg.AppID), nil
+	case cfg.Key == "":
+		return fmt.Sprintf("%s is public and readable without a token", g.RepoName()), nil
+	}
+
+	header, classic := resp.Header["X-Oauth-Scopes"]
+	if !classic {
+		return fmt.Sprintf("%s is readable with a fine-grained token", g.RepoName()), nil
+	}
+	var scopes []string
+	for _, scope := range strings.Split(strings.Join(header, ","), ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			scopes = append(scopes, scope)
+		}
+	}
+	if repo.Private && !hasScope(scopes, "repo") {
+		return "", fmt.Errorf("%s is private but the token only has scopes [%s], it needs repo", g.RepoName(), strings.Join(scopes, ", "))
+	}
+	return fmt.Sprintf("%s is readable with a token with scopes [%s]", g.RepoName(), strings.Join(scopes, ", ")), nil
+}
+
+// hasScope reports whether scopes contains scope.
+func hasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package github
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"autopuller/source"
+)
+
+// TestCheckAccess checks that a classic token needs the repo scope for a
+// private repository, and that a fine-grained token is accepted.
+func TestCheckAccess(t *testing.T) {
+	tests := []struct {
+		name    string
+		private bool
+		scopes  []string
+		status  int
+		want    string
+		wantErr bool
+	}{
+		{name: "classic with repo", private: true, scopes: []string{"repo, workflow"}, want: "scopes [repo, workflow]"},
+		{name: "classic without repo", private: true, scopes: []string{"read:org"}, wantErr: true},
+		{name: "classic on public repo", scopes: []string{""}, want: "scopes []"},
+		{name: "fine-grained", private: true, want: "fine-grained"},
+		{name: "not found", status: http.StatusNotFound, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/v3/repos/user/repo" {
+					t.Errorf("Expected request to '/api/v3/repos/user/repo', got '%s'", r.URL.Path)
+				}
+				if tt.scopes != nil {
+					w.Header()["X-OAuth-Scopes"] = tt.scopes
+				}
+				if tt.status != 0 {
+					w.WriteHeader(tt.status)
+					return
+				}
+				if tt.private {
+					w.Write([]byte(`{"private": true}`))
+				} else {
+					w.Write([]byte(`{"private": false}`))
+				}
+			}))
+			defer ts.Close()
+
+			os.Setenv("GITHUB_API_URL", ts.URL+"/api/v3")
+			os.Setenv("GITHUBKEY", "fake-key")
+			defer os.Unsetenv("GITHUB_API_URL")
+			defer os.Unsetenv("GITHUBKEY")
+
+			desc, err := (&RealGitHubAPI{GitCheckout: source.GitCheckout{Repo: "user/repo"}}).CheckAccess(context.Background())
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Expected an error, but got '%s'", desc)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if !strings.Contains(desc, tt.want) {
+				t.Fatalf("Expected the description to mention '%s', got '%s'", tt.want, desc)
+			}
+		})
+	}
+}
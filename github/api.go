@@ -2,189 +2,52 @@ package github
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"log"
-	"net/http"
-	"os"
-	"os/exec"
-	"path/filepath"
+	"net/url"
 	"strings"
-)
 
-// GitHubAPI is an interface that defines the functions interacting with GitHub.
-// This is for testing
-type GitHubAPI interface {
-	GetMasterSum(ctx context.Context) (string, error)
-	GetCurrentSum() (string, error)
-	CheckLastRun(ctx context.Context, sha string) (bool, error)
-	CheckDifferences(ctx context.Context, oldSha, newSha string) ([]string, error)
-	RunGitPull(ctx context.Context, repoDir string) error
-}
+	"autopuller/env"
+	"autopuller/source"
+)
 
+// RealGitHubAPI is the GitHub implementation of source.Source.
 type RealGitHubAPI struct {
+	source.GitCheckout
 }
 
-// GetMasterSum fetches the latest commit SHA from GitHub for the master branch.
-func (g *RealGitHubAPI) GetMasterSum(ctx context.Context) (string, error) {
-	// Define default URL prefix
-	defaultURLPrefix := "https://api.github.com/repos/"
-
-	// Check if we have a URL override for the prefix, otherwise use the default
-	urlPrefix := os.Getenv("GITHUB_URL_PREFIX")
-	if urlPrefix == "" {
-		urlPrefix = defaultURLPrefix
-	}
-
-	// Retrieve the repository name from environment variables
-	repoName := os.Getenv("REPONAME")
-	if repoName == "" {
-		return "", fmt.Errorf("REPONAME environment variable not set")
-	}
-
-	// Construct the final URL using the prefix and repository name
-	url := fmt.Sprintf("%s%s/commits/master", urlPrefix, repoName)
-
-	//log.Println("Request URL:", url)
-
-	// Prepare the request
-	req, _ := http.NewRequest("GET", url, nil)
-	if githubkey := os.Getenv("GITHUBKEY"); githubkey != "" {
-		req.Header.Set("Authorization", "token "+githubkey)
+// GetBranchSum fetches the latest commit SHA from GitHub for the given branch.
+func (g *RealGitHubAPI) GetBranchSum(ctx context.Context, branch string) (string, error) {
+	if g.RepoName() == "" {
+		return "", source.Fatal(fmt.Errorf("repo (REPONAME) is not set"))
 	}
 
-	// Make the request
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	// Decode the response
 	var result struct {
 		Sha string `json:"sha"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+	if _, err := g.getJSON(ctx, g.repoURL("/commits/"+url.PathEscape(branch)), &result); err != nil {
 		return "", err
 	}
-
 	return result.Sha, nil
 }
 
-// GetCurrentSum reads the current commit SHA from the local file system.
-func (g *RealGitHubAPI) GetCurrentSum() (string, error) {
-	repoDir := os.Getenv("REPODIR")
-	if err := os.Chdir(repoDir); err != nil {
-		return "", err
-	}
-
-	var masterFile = filepath.Join(os.Getenv("REPODIR"), ".git/refs/heads/master")
-	filename := filepath.FromSlash(masterFile)
-
-	data, err := ioutil.ReadFile(filename)
-	if err != nil {
-		return "", err
-	}
-	return strings.TrimSpace(string(data)), nil
-}
-
-// CheckLastRun checks if the last GitHub Actions run for the commit was successful.
-func (g *RealGitHubAPI) CheckLastRun(ctx context.Context, sha string) (bool, error) {
-	// Define default GitHub API URL prefix
-	defaultURLPrefix := "https://api.github.com/repos/"
-
-	// Check if we have an override for the URL prefix (for testing purposes)
-	urlPrefix := os.Getenv("GITHUB_URL_PREFIX")
-	if urlPrefix == "" {
-		urlPrefix = defaultURLPrefix
-	}
-	// Construct the final URL for the GitHub Actions API
-	url := fmt.Sprintf("%s%s/actions/runs", urlPrefix, os.Getenv("REPONAME"))
-
-	log.Println("URL:", url)
-	// Create a new HTTP GET request
-	req, _ := http.NewRequest("GET", url, nil)
-	req.Header.Set("Authorization", "token "+os.Getenv("GITHUBKEY"))
-
-	// Send the HTTP request and handle errors
-	resp, err := http.DefaultClient.Do(req)
-
-	if err != nil {
-		log.Fatal("HTTP Error:", err)
-		return false, err
-	}
-	defer resp.Body.Close()
-
-	// Check for HTTP OK
-	if resp.StatusCode != 200 {
-		log.Println("Unexpected status code: ", resp.StatusCode)
-		return false, err
-	}
-
-	// Parse the response body
-	var result struct {
-		WorkflowRuns []struct {
-			HeadSha    string `json:"head_sha"`
-			Conclusion string `json:"conclusion"`
-		} `json:"workflow_runs"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		log.Fatal("Error:", err)
-		return false, err
-	}
-
-	// Check if the run corresponding to the given SHA was successful
-
-	for _, run := range result.WorkflowRuns {
-
-		log.Println("Run:", run)
-		if run.HeadSha == sha && run.Conclusion == "success" {
-			return true, nil
-		}
-	}
-
-	return false, nil
-}
+// maxCompareFiles is the most files the compare endpoint returns; a list of
+// exactly this many has been truncated.
+const maxCompareFiles = 300
 
 // CheckDifferences compares two SHAs and returns a list of changed files.
+// The compare endpoint only pages through commits and lists the files on the
+// first page alone, capped at maxCompareFiles, so a single page is fetched
+// and a capped list is computed locally with git diff instead.
 func (g *RealGitHubAPI) CheckDifferences(ctx context.Context, oldSha, newSha string) ([]string, error) {
-	// Use GITHUB_URL_PREFIX if set, otherwise default to the GitHub API URL.
-	urlPrefix := os.Getenv("GITHUB_URL_PREFIX")
-	if urlPrefix == "" {
-		urlPrefix = "https://api.github.com/repos/"
-	}
-
-	// Construct the full URL using the prefix and the SHAs.
-	url := fmt.Sprintf("%s%s/compare/%s...%s", urlPrefix, os.Getenv("REPONAME"), oldSha, newSha)
-
-	log.Println("CheckDifferences URL:", url)
-
-	// Create the HTTP request
-	req, _ := http.NewRequest("GET", url, nil)
-	req.Header.Set("Authorization", "token "+os.Getenv("GITHUBKEY"))
+	url := g.repoURL(fmt.Sprintf("/compare/%s...%s", oldSha, newSha))
+	source.Logger(ctx).Println("CheckDifferences URL:", url)
 
-	// Send the request
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	// Check for HTTP OK
-	if resp.StatusCode != 200 {
-		log.Println("Unexpected status code: ", resp.StatusCode)
-		return nil, err
-	}
-
-	// Decode the JSON response
 	var result struct {
 		Files []struct {
 			Filename string `json:"filename"`
 		} `json:"files"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+	if _, err := g.getJSON(ctx, url, &result); err != nil {
 		return nil, err
 	}
 
@@ -194,37 +57,104 @@ func (g *RealGitHubAPI) CheckDifferences(ctx context.Context, oldSha, newSha str
 		diffs = append(diffs, file.Filename)
 	}
 
+	if len(diffs) >= maxCompareFiles {
+		source.Logger(ctx).Printf("Compare of %s...%s lists %d files and may be truncated, using git diff instead", oldSha, newSha, len(diffs))
+		return localDifferences(ctx, g.RepoDir(), oldSha, newSha)
+	}
 	return diffs, nil
 }
 
-// Define function variables that can be overridden in tests
-var chdir = os.Chdir
-var execCommandContext = exec.CommandContext
+// localDifferences lists changed files with git, overridable in tests.
+var localDifferences = source.LocalDifferences
+
+// ListCommits lists the SHAs of the commits after oldSha up to and including
+// newSha on the first-parent line of newSha, oldest first.
+func (g *RealGitHubAPI) ListCommits(ctx context.Context, oldSha, newSha string) ([]string, error) {
+	parents := map[string][]string{}
+	url := g.repoURL(fmt.Sprintf("/compare/%s...%s?per_page=100", oldSha, newSha))
+	for url != "" {
+		var page struct {
+			Commits []struct {
+				Sha     string `json:"sha"`
+				Parents []struct {
+					Sha string `json:"sha"`
+				} `json:"parents"`
+			} `json:"commits"`
+		}
+		next, err := g.getJSON(ctx, url, &page)
+		if err != nil {
+			return nil, err
+		}
+		for _, commit := range page.Commits {
+			parents[commit.Sha] = nil
+			for _, parent := range commit.Parents {
+				parents[commit.Sha] = append(parents[commit.Sha], parent.Sha)
+			}
+		}
+		url = next
+	}
+	return source.FirstParentLine(newSha, parents), nil
+}
 
-// RunGitPull runs git-related commands to update the repository.
-func (g *RealGitHubAPI) RunGitPull(ctx context.Context, repoDir string) error {
-	// Change directory to the repoDir
-	if err := chdir(repoDir); err != nil {
-		return err
+// defaultAPIURL is the REST API base of github.com.
+const defaultAPIURL = "https://api.github.com"
+
+// apiBase returns the REST API base URL. GITHUB_API_URL selects a GitHub
+// Enterprise Server instance; given only the host, its /api/v3 path is added.
+func apiBase() string {
+	cfg := env.Current().GitHub
+	base := cfg.APIURL
+	if base == "" {
+		if prefix := cfg.URLPrefix; prefix != "" {
+			return strings.TrimSuffix(strings.TrimSuffix(prefix, "/"), "/repos")
+		}
+		return defaultAPIURL
+	}
+	if u, err := url.Parse(base); err == nil && strings.Trim(u.Path, "/") == "" && u.Host != "api.github.com" {
+		return strings.TrimSuffix(base, "/") + "/api/v3"
 	}
+	return base
+}
+
+// apiURL builds an API URL for a path, with an optional query.
+func apiURL(path string) string {
+	return joinURL(apiBase(), path)
+}
 
-	// Set git credential helper and safe directory
-	commands := [][]string{
-		{"git", "config", "credential.helper", "store"},
-		{"git", "config", "--global", "--add", "safe.directory", repoDir},
-		{"git", "pull"},
+// repoURL builds an API URL for a path under the given repository.
+func repoURL(repo, path string) string {
+	if cfg := env.Current().GitHub; cfg.URLPrefix != "" && cfg.APIURL == "" {
+		// The older GITHUB_URL_PREFIX already includes the /repos/ path
+		return joinURL(cfg.URLPrefix, repo+path)
 	}
+	return apiURL("/repos/" + repo + path)
+}
 
-	for _, cmdArgs := range commands {
-		cmd := execCommandContext(ctx, cmdArgs[0], cmdArgs[1:]...)
-		output, err := cmd.CombinedOutput()
-		if err != nil {
-			log.Printf("Error running command %s: %v", cmdArgs[0], err)
-			log.Printf("Output: %s", output)
-			return fmt.Errorf("failed to run git command: %s", cmdArgs)
-		}
-		log.Printf("Command %s successful: %s", cmdArgs[0], string(output))
+// repoURL builds an API URL for a path under the followed repository.
+func (g *RealGitHubAPI) repoURL(path string) string {
+	return repoURL(g.RepoName(), path)
+}
+
+// joinURL appends a path, with an optional query, to a base URL whether or
+// not the base ends in a slash. An unparseable base is joined as text so the
+// request itself reports the error.
+func joinURL(base, path string) string {
+	u, err := url.Parse(base)
+	if err != nil {
+		return base + path
+	}
+	ref, err := url.Parse(strings.TrimPrefix(path, "/"))
+	if err != nil {
+		return base + path
 	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/"
+	u.RawPath = ""
+	return u.ResolveReference(ref).String()
+}
 
-	return nil
+// getJSON performs a GET request authenticated for the followed repository
+// and decodes the JSON response into result. It returns the URL of the next
+// page, if any.
+func (g *RealGitHubAPI) getJSON(ctx context.Context, url string, result interface{}) (string, error) {
+	return source.GetJSONWith(withRepo(ctx, g.RepoName()), client, url, nil, result)
 }
@@ -2,17 +2,20 @@ package github
 
 import (
 	"context"
-	"io/ioutil"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
-	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"autopuller/source"
 )
 
 // Mock HTTP client using httptest to simulate GitHub API responses.
 
-func TestGetMasterSum_Success(t *testing.T) {
+func TestGetBranchSum_Success(t *testing.T) {
 	// Set up a fake GitHub API server
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path != "/fake-repo/commits/master" {
@@ -33,8 +36,8 @@ func TestGetMasterSum_Success(t *testing.T) {
 	// Create an instance of RealGitHubAPI
 	github := &RealGitHubAPI{}
 
-	// Call GetMasterSum and check the result
-	sha, err := github.GetMasterSum(context.Background())
+	// Call GetBranchSum and check the result
+	sha, err := github.GetBranchSum(context.Background(), "master")
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -43,7 +46,12 @@ func TestGetMasterSum_Success(t *testing.T) {
 	}
 }
 
-func TestGetMasterSum_Failure(t *testing.T) {
+func TestGetBranchSum_Failure(t *testing.T) {
+	// Server errors are retried, without waiting in tests
+	retryDelay := source.RetryDelay
+	source.RetryDelay = time.Millisecond
+	defer func() { source.RetryDelay = retryDelay }()
+
 	// Set up a fake GitHub API server
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "GitHub API failure", http.StatusInternalServerError)
@@ -60,124 +68,213 @@ func TestGetMasterSum_Failure(t *testing.T) {
 	// Create an instance of RealGitHubAPI
 	github := &RealGitHubAPI{}
 
-	// Call GetMasterSum and expect an error
-	_, err := github.GetMasterSum(context.Background())
+	// Call GetBranchSum and expect an error
+	_, err := github.GetBranchSum(context.Background(), "master")
 	if err == nil {
 		t.Fatalf("Expected an error, but got nil")
 	}
 }
 
-// Test GetCurrentSum by mocking file system operations.
-func TestGetCurrentSum_Success(t *testing.T) {
-	// Manually create a temporary directory
-	repoDir, err := ioutil.TempDir("", "repo")
-	if err != nil {
-		t.Fatalf("Failed to create temporary directory: %v", err)
-	}
-	defer os.RemoveAll(repoDir) // Clean up after the test
+func TestCheckDifferences_Success(t *testing.T) {
+	// Set up a fake GitHub API server
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Verify the request URL
+		if r.URL.Path != "/repos/fake-repo/compare/oldSha...newSha" {
+			t.Errorf("Expected request to '/repos/fake-repo/compare/oldSha...newSha', got '%s'", r.URL.Path)
+		}
+
+		// Respond with a fake list of changed files
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{
+			"files": [
+				{"filename": "file1.txt"},
+				{"filename": "file2.txt"}
+			]
+		}`))
+	}))
+	defer ts.Close()
 
-	// Mock REPODIR environment variable
-	os.Setenv("REPODIR", repoDir)
+	// Set environment variables for the test
+	os.Setenv("REPONAME", "fake-repo")
+	os.Setenv("GITHUBKEY", "fake-key")
 
-	// Create a fake master file with a commit SHA
-	masterFile := filepath.Join(repoDir, ".git/refs/heads/master")
-	os.MkdirAll(filepath.Dir(masterFile), 0755)
-	ioutil.WriteFile(masterFile, []byte("fake-local-sha"), 0644)
+	// Use the test server's URL as a mock GitHub API endpoint
+	os.Setenv("GITHUB_URL_PREFIX", ts.URL+"/repos/")
 
 	// Create an instance of RealGitHubAPI
 	github := &RealGitHubAPI{}
 
-	// Call GetCurrentSum and check the result
-	sha, err := github.GetCurrentSum()
+	// Call CheckDifferences and check the result
+	diffs, err := github.CheckDifferences(context.Background(), "oldSha", "newSha")
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
-	if sha != "fake-local-sha" {
-		t.Fatalf("Expected SHA 'fake-local-sha', got '%s'", sha)
+
+	// Check if the returned list of differences matches the expected output
+	expectedDiffs := []string{"file1.txt", "file2.txt"}
+	if len(diffs) != len(expectedDiffs) {
+		t.Fatalf("Expected %d differences, got %d", len(expectedDiffs), len(diffs))
+	}
+	for i, diff := range diffs {
+		if diff != expectedDiffs[i] {
+			t.Fatalf("Expected difference '%s', got '%s'", expectedDiffs[i], diff)
+		}
 	}
 }
 
-func TestCheckLastRun_Success(t *testing.T) {
-	// Set up a fake GitHub API server
-	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path != "/repos/fake-repo/actions/runs" {
-			t.Errorf("Expected request to '/repos/fake-repo/actions/runs', got '%s'", r.URL.Path)
+func TestListCommits_Success(t *testing.T) {
+	// Set up a fake GitHub API server returning two pages of commits
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/fake-repo/compare/oldSha...newSha" {
+			t.Errorf("Expected request to '/repos/fake-repo/compare/oldSha...newSha', got '%s'", r.URL.Path)
 		}
 		w.Header().Set("Content-Type", "application/json")
-		w.Write([]byte(`{
-			"workflow_runs": [
-				{
-					"head_sha": "fake-sha",
-					"conclusion": "success"
-				}
-			]
-		}`))
+		if r.URL.Query().Get("page") == "" {
+			w.Header().Set("Link", `<`+ts.URL+`/repos/fake-repo/compare/oldSha...newSha?page=2>; rel="next"`)
+			w.Write([]byte(`{"commits": [{"sha": "sha1", "parents": [{"sha": "oldSha"}]}, {"sha": "side", "parents": [{"sha": "oldSha"}]}]}`))
+			return
+		}
+		// sha2 merges in side, which is not on the first-parent line
+		w.Write([]byte(`{"commits": [{"sha": "sha2", "parents": [{"sha": "sha1"}, {"sha": "side"}]}, {"sha": "newSha", "parents": [{"sha": "sha2"}]}]}`))
 	}))
 	defer ts.Close()
 
-	// Set environment variables
 	os.Setenv("REPONAME", "fake-repo")
-	os.Setenv("GITHUBKEY", "fake-key")
-
-	// Set the environment variable to override the URL prefix with the test server URL
 	os.Setenv("GITHUB_URL_PREFIX", ts.URL+"/repos/")
 
-	// Create an instance of RealGitHubAPI
 	github := &RealGitHubAPI{}
+	commits, err := github.ListCommits(context.Background(), "oldSha", "newSha")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expected := []string{"sha1", "sha2", "newSha"}
+	if len(commits) != len(expected) {
+		t.Fatalf("Expected %d commits, got %d", len(expected), len(commits))
+	}
+	for i, commit := range commits {
+		if commit != expected[i] {
+			t.Fatalf("Expected commit '%s', got '%s'", expected[i], commit)
+		}
+	}
+}
 
-	// Call CheckLastRun and check the result
-	success, err := github.CheckLastRun(context.Background(), "fake-sha")
+// TestCheckDifferences_FirstPageOnly checks that only the first page of the
+// compare endpoint is fetched: like GitHub, the fake server links to more
+// pages of commits but lists the files on the first page alone.
+func TestCheckDifferences_FirstPageOnly(t *testing.T) {
+	requests := 0
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("page") == "" {
+			w.Header().Set("Link", `<`+ts.URL+`/repos/fake-repo/compare/oldSha...newSha?page=2>; rel="next"`)
+			w.Write([]byte(`{"commits": [{"sha": "sha1"}], "files": [{"filename": "file1.txt"}, {"filename": "file2.txt"}]}`))
+			return
+		}
+		w.Write([]byte(`{"commits": [{"sha": "newSha"}]}`))
+	}))
+	defer ts.Close()
+
+	os.Setenv("REPONAME", "fake-repo")
+	os.Setenv("GITHUB_URL_PREFIX", ts.URL+"/repos/")
+
+	github := &RealGitHubAPI{}
+	diffs, err := github.CheckDifferences(context.Background(), "oldSha", "newSha")
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
-	if !success {
-		t.Fatalf("Expected success, but got failure")
+	if len(diffs) != 2 || diffs[0] != "file1.txt" || diffs[1] != "file2.txt" {
+		t.Fatalf("Expected [file1.txt file2.txt], got %v", diffs)
+	}
+	if requests != 1 {
+		t.Fatalf("Expected 1 request, got %d", requests)
 	}
 }
 
-func TestCheckDifferences_Success(t *testing.T) {
-	// Set up a fake GitHub API server
+func TestCheckDifferences_Truncated(t *testing.T) {
+	// Set up a fake GitHub API server returning a capped file list
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Verify the request URL
-		if r.URL.Path != "/repos/fake-repo/compare/oldSha...newSha" {
-			t.Errorf("Expected request to '/repos/fake-repo/compare/oldSha...newSha', got '%s'", r.URL.Path)
+		var files []string
+		for i := 0; i < maxCompareFiles; i++ {
+			files = append(files, fmt.Sprintf(`{"filename": "file%d.txt"}`, i))
 		}
-
-		// Respond with a fake list of changed files
 		w.Header().Set("Content-Type", "application/json")
-		w.Write([]byte(`{
-			"files": [
-				{"filename": "file1.txt"},
-				{"filename": "file2.txt"}
-			]
-		}`))
+		w.Write([]byte(`{"files": [` + strings.Join(files, ",") + `]}`))
 	}))
 	defer ts.Close()
 
-	// Set environment variables for the test
 	os.Setenv("REPONAME", "fake-repo")
-	os.Setenv("GITHUBKEY", "fake-key")
-
-	// Use the test server's URL as a mock GitHub API endpoint
 	os.Setenv("GITHUB_URL_PREFIX", ts.URL+"/repos/")
 
-	// Create an instance of RealGitHubAPI
-	github := &RealGitHubAPI{}
+	// The local git diff lists the changed files instead
+	originalLocalDifferences := localDifferences
+	defer func() {
+		localDifferences = originalLocalDifferences
+	}()
+	localDifferences = func(ctx context.Context, repoDir, oldSha, newSha string) ([]string, error) {
+		return []string{"local1.txt", "local2.txt"}, nil
+	}
 
-	// Call CheckDifferences and check the result
+	github := &RealGitHubAPI{}
 	diffs, err := github.CheckDifferences(context.Background(), "oldSha", "newSha")
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
+	if len(diffs) != 2 || diffs[0] != "local1.txt" || diffs[1] != "local2.txt" {
+		t.Fatalf("Expected the local diff [local1.txt local2.txt], got %v", diffs)
+	}
+}
 
-	// Check if the returned list of differences matches the expected output
-	expectedDiffs := []string{"file1.txt", "file2.txt"}
-	if len(diffs) != len(expectedDiffs) {
-		t.Fatalf("Expected %d differences, got %d", len(expectedDiffs), len(diffs))
+func TestCheckDifferences_Failure(t *testing.T) {
+	// Server errors are retried, without waiting in tests
+	retryDelay := source.RetryDelay
+	source.RetryDelay = time.Millisecond
+	defer func() { source.RetryDelay = retryDelay }()
+
+	// Set up a fake GitHub API server
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "GitHub API failure", http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	os.Setenv("REPONAME", "fake-repo")
+	os.Setenv("GITHUB_URL_PREFIX", ts.URL+"/repos/")
+
+	// An error must not look like an empty change list
+	github := &RealGitHubAPI{}
+	if _, err := github.CheckDifferences(context.Background(), "oldSha", "newSha"); err == nil {
+		t.Fatalf("Expected an error, but got nil")
 	}
-	for i, diff := range diffs {
-		if diff != expectedDiffs[i] {
-			t.Fatalf("Expected difference '%s', got '%s'", expectedDiffs[i], diff)
+}
+
+// TestRepoURL checks URL building for github.com, GitHub Enterprise Server and
+// the older GITHUB_URL_PREFIX, with and without trailing slashes.
+func TestRepoURL(t *testing.T) {
+	defer os.Unsetenv("GITHUB_API_URL")
+	defer os.Unsetenv("GITHUB_URL_PREFIX")
+	os.Setenv("REPONAME", "user/repo")
+
+	tests := []struct {
+		apiURL string
+		prefix string
+		want   string
+	}{
+		{want: "https://api.github.com/repos/user/repo/commits/feature%2Fx?per_page=100"},
+		{apiURL: "https://ghe.example.com/api/v3", want: "https://ghe.example.com/api/v3/repos/user/repo/commits/feature%2Fx?per_page=100"},
+		{apiURL: "https://ghe.example.com/api/v3/", want: "https://ghe.example.com/api/v3/repos/user/repo/commits/feature%2Fx?per_page=100"},
+		{apiURL: "https://ghe.example.com", want: "https://ghe.example.com/api/v3/repos/user/repo/commits/feature%2Fx?per_page=100"},
+		{apiURL: "https://api.github.com/", want: "https://api.github.com/repos/user/repo/commits/feature%2Fx?per_page=100"},
+		{prefix: "http://127.0.0.1:8080/repos/", want: "http://127.0.0.1:8080/repos/user/repo/commits/feature%2Fx?per_page=100"},
+		{prefix: "http://127.0.0.1:8080/repos", want: "http://127.0.0.1:8080/repos/user/repo/commits/feature%2Fx?per_page=100"},
+		{prefix: "http://127.0.0.1:8080", want: "http://127.0.0.1:8080/user/repo/commits/feature%2Fx?per_page=100"},
+	}
+	for _, tt := range tests {
+		os.Setenv("GITHUB_API_URL", tt.apiURL)
+		os.Setenv("GITHUB_URL_PREFIX", tt.prefix)
+		if got := (&RealGitHubAPI{}).repoURL("/commits/feature%2Fx?per_page=100"); got != tt.want {
+			t.Errorf("GITHUB_API_URL=%q GITHUB_URL_PREFIX=%q: expected %s, got %s", tt.apiURL, tt.prefix, tt.want, got)
 		}
 	}
 }
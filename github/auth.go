@@ -0,0 +1,204 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"autopuller/env"
+	"autopuller/source"
+)
+
+// authTransport adds the Authorization header to each request: a GitHub App
+// installation token when GITHUB_APP_ID is set, otherwise the personal access
+// token in GITHUBKEY, if any.
+type authTransport struct{}
+
+// repoKey is the context key of the repository a request is made for.
+type repoKey struct{}
+
+// withRepo records the repository a request is made for, so authTransport
+// can pick the GitHub App installation for it.
+func withRepo(ctx context.Context, repo string) context.Context {
+	return context.WithValue(ctx, repoKey{}, repo)
+}
+
+// requestRepo returns the repository a request is made for, or the
+// configured repo.
+func requestRepo(ctx context.Context) string {
+	if repo, ok := ctx.Value(repoKey{}).(string); ok && repo != "" {
+		return repo
+	}
+	return env.Current().Repo
+}
+
+// RoundTrip authenticates the request and sends it over source.Transport.
+func (authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var token string
+	if env.Current().GitHub.AppID != "" {
+		var err error
+		if token, err = appTokens.token(req.Context(), requestRepo(req.Context())); err != nil {
+			return nil, err
+		}
+	} else {
+		token = env.Current().GitHub.Key
+	}
+
+	if token != "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("Authorization", "token "+token)
+	}
+	return source.Transport().RoundTrip(req)
+}
+
+// tokenRefreshMargin is how long before it expires an installation token is
+// replaced, so a request never goes out with a token about to lapse.
+const tokenRefreshMargin = 5 * time.Minute
+
+// installationTokens caches the installation tokens of the GitHub App.
+type installationTokens struct {
+	mu     sync.Mutex
+	tokens map[string]installationToken
+	now    func() time.Time
+}
+
+// installationToken is an installation access token and its expiry.
+type installationToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// appTokens is the token cache used by authTransport.
+var appTokens = newInstallationTokens()
+
+// newInstallationTokens creates an empty token cache.
+func newInstallationTokens() *installationTokens {
+	return &installationTokens{tokens: map[string]installationToken{}, now: time.Now}
+}
+
+// token returns a valid installation token for the configured app and the
+// repository, exchanging a freshly signed JWT for a new one when needed.
+// GITHUB_APP_INSTALLATION_ID may be left empty to look the installation up
+// from the repository.
+func (c *installationTokens) token(ctx context.Context, repo string) (string, error) {
+	cfg := env.Current().GitHub
+	appID := cfg.AppID
+	installation := cfg.AppInstallationID
+	key := strings.Join([]string{apiBase(), appID, installation, repo}, "|")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cached, ok := c.tokens[key]; ok && c.now().Before(cached.ExpiresAt.Add(-tokenRefreshMargin)) {
+		return cached.Token, nil
+	}
+
+	jwt, err := appJWT(appID, cfg.AppPrivateKey, c.now())
+	if err != nil {
+		return "", source.Fatal(err)
+	}
+
+	if installation == "" {
+		var result struct {
+			ID int64 `json:"id"`
+		}
+		if err := appRequest(ctx, "GET", repoURL(repo, "/installation"), jwt, &result); err != nil {
+			return "", fmt.Errorf("failed to find the GitHub App installation: %w", err)
+		}
+		installation = fmt.Sprint(result.ID)
+	}
+
+	var fresh installationToken
+	if err := appRequest(ctx, "POST", apiURL("/app/installations/"+installation+"/access_tokens"), jwt, &fresh); err != nil {
+		return "", fmt.Errorf("failed to create a GitHub App installation token: %w", err)
+	}
+	source.Logger(ctx).Printf("Created GitHub App installation token for installation %s, valid until %s", installation, fresh.ExpiresAt.Format(time.RFC3339))
+	c.tokens[key] = fresh
+	return fresh.Token, nil
+}
+
+// appRequest sends a request authenticated as the app itself and decodes the
+// JSON response into result.
+func appRequest(ctx context.Context, method, url, jwt string, result interface{}) error {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := (&http.Client{Transport: source.Transport()}).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return &source.StatusError{Code: resp.StatusCode, URL: url}
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// appJWT signs the RS256 JSON Web Token that identifies the app. It is valid
+// for nine minutes (GitHub allows ten), backdated a minute for clock drift.
+func appJWT(appID, keyFile string, now time.Time) (string, error) {
+	key, err := loadPrivateKey(keyFile)
+	if err != nil {
+		return "", err
+	}
+
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": appID,
+	})
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign GitHub App JWT: %v", err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// loadPrivateKey reads the app's PEM private key, in the PKCS#1 form GitHub
+// generates or PKCS#8.
+func loadPrivateKey(path string) (*rsa.PrivateKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GITHUB_APP_PRIVATE_KEY: %v", err)
+	}
+	block, _ := pem.Decode(bytes.TrimSpace(data))
+	if block == nil {
+		return nil, fmt.Errorf("GITHUB_APP_PRIVATE_KEY %s is not a PEM file", path)
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse GITHUB_APP_PRIVATE_KEY: %v", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("GITHUB_APP_PRIVATE_KEY %s is not an RSA key", path)
+	}
+	return key, nil
+}
@@ -0,0 +1,187 @@
+package github
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"autopuller/source"
+)
+
+// newFakeApp serves the GitHub App endpoints, checking the JWT against the
+// key, and a commits endpoint that only accepts the current installation token.
+// The API is served under /api/v3, as on GitHub Enterprise Server. It returns
+// the server and the number of tokens it has issued.
+func newFakeApp(t *testing.T, key *rsa.PrivateKey, now func() time.Time) (*httptest.Server, *int) {
+	issued := 0
+	ts := httptest.NewServer(http.StripPrefix("/api/v3", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/user/repo/installation", "/app/installations/42/access_tokens":
+			verifyJWT(t, r.Header.Get("Authorization"), &key.PublicKey)
+			if r.URL.Path == "/repos/user/repo/installation" {
+				w.Write([]byte(`{"id": 42}`))
+				return
+			}
+			if r.Method != "POST" {
+				t.Errorf("Expected POST for an installation token, got %s", r.Method)
+			}
+			issued++
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"token": "inst-token-%d", "expires_at": %q}`, issued, now().Add(time.Hour).Format(time.RFC3339))
+		case "/repos/user/repo/commits/main":
+			if want := fmt.Sprintf("token inst-token-%d", issued); r.Header.Get("Authorization") != want {
+				t.Errorf("Expected Authorization '%s', got '%s'", want, r.Header.Get("Authorization"))
+			}
+			w.Write([]byte(`{"sha": "fake-sha"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	})))
+	return ts, &issued
+}
+
+// verifyJWT checks the app JWT's signature and issuer. It runs in the
+// server's goroutine, so it reports with Errorf.
+func verifyJWT(t *testing.T, authorization string, key *rsa.PublicKey) {
+	t.Helper()
+	parts := strings.Split(strings.TrimPrefix(authorization, "Bearer "), ".")
+	if len(parts) != 3 {
+		t.Errorf("Expected a Bearer JWT, got '%s'", authorization)
+		return
+	}
+	signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		t.Errorf("Expected a valid JWT signature, got %v", err)
+	}
+
+	var claims struct {
+		Iss string `json:"iss"`
+		Iat int64  `json:"iat"`
+		Exp int64  `json:"exp"`
+	}
+	payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	json.Unmarshal(payload, &claims)
+	if claims.Iss != "1234" || claims.Exp-claims.Iat > 600 {
+		t.Errorf("Expected issuer 1234 and a lifetime of at most 10 minutes, got %+v", claims)
+	}
+}
+
+// writeKey writes the key as a PKCS#1 PEM file, as GitHub generates them.
+func writeKey(t *testing.T, key *rsa.PrivateKey) string {
+	file, err := ioutil.TempFile("", "app-*.pem")
+	if err != nil {
+		t.Fatalf("Failed to create key file: %v", err)
+	}
+	defer file.Close()
+	pem.Encode(file, &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	return file.Name()
+}
+
+// TestGitHubAppAuth tests that requests use a cached installation token that
+// is refreshed shortly before it expires.
+func TestGitHubAppAuth(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	keyFile := writeKey(t, key)
+	defer os.Remove(keyFile)
+
+	current := time.Now()
+	appTokens = newInstallationTokens()
+	appTokens.now = func() time.Time { return current }
+	defer func() { appTokens = newInstallationTokens() }()
+
+	ts, issued := newFakeApp(t, key, appTokens.now)
+	defer ts.Close()
+
+	os.Setenv("REPONAME", "user/repo")
+	os.Setenv("GITHUB_API_URL", ts.URL)
+	os.Setenv("GITHUB_APP_ID", "1234")
+	os.Setenv("GITHUB_APP_PRIVATE_KEY", keyFile)
+	defer os.Unsetenv("GITHUB_API_URL")
+	defer os.Unsetenv("GITHUB_APP_ID")
+	defer os.Unsetenv("GITHUB_APP_PRIVATE_KEY")
+
+	github := &RealGitHubAPI{}
+	for i := 0; i < 2; i++ {
+		if sha, err := github.GetBranchSum(context.Background(), "main"); err != nil || sha != "fake-sha" {
+			t.Fatalf("Expected SHA 'fake-sha', got '%s' (%v)", sha, err)
+		}
+	}
+	if *issued != 1 {
+		t.Fatalf("Expected the token to be reused, but %d were issued", *issued)
+	}
+
+	// 56 minutes later the token is within the refresh margin
+	current = current.Add(56 * time.Minute)
+	if _, err := github.GetBranchSum(context.Background(), "main"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if *issued != 2 {
+		t.Fatalf("Expected the token to be refreshed, but %d were issued", *issued)
+	}
+}
+
+// TestGitHubAppAuth_BadKey tests that an unreadable private key fails the request.
+func TestGitHubAppAuth_BadKey(t *testing.T) {
+	appTokens = newInstallationTokens()
+	os.Setenv("REPONAME", "user/repo")
+	os.Setenv("GITHUB_APP_ID", "1234")
+	os.Setenv("GITHUB_APP_PRIVATE_KEY", "/nonexistent/app.pem")
+	defer os.Unsetenv("GITHUB_APP_ID")
+	defer os.Unsetenv("GITHUB_APP_PRIVATE_KEY")
+
+	github := &RealGitHubAPI{}
+	if _, err := github.GetBranchSum(context.Background(), "main"); err == nil {
+		t.Fatalf("Expected an error, but got nil")
+	}
+}
+
+// TestGitHubAppAuth_ProjectRepo tests that the installation is looked up for
+// the repository of the backend rather than REPONAME.
+func TestGitHubAppAuth_ProjectRepo(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	keyFile := writeKey(t, key)
+	defer os.Remove(keyFile)
+
+	appTokens = newInstallationTokens()
+	defer func() { appTokens = newInstallationTokens() }()
+
+	ts, issued := newFakeApp(t, key, time.Now)
+	defer ts.Close()
+
+	os.Setenv("REPONAME", "other/repo")
+	os.Setenv("GITHUB_API_URL", ts.URL)
+	os.Setenv("GITHUB_APP_ID", "1234")
+	os.Setenv("GITHUB_APP_PRIVATE_KEY", keyFile)
+	defer os.Unsetenv("GITHUB_API_URL")
+	defer os.Unsetenv("GITHUB_APP_ID")
+	defer os.Unsetenv("GITHUB_APP_PRIVATE_KEY")
+
+	github := &RealGitHubAPI{GitCheckout: source.GitCheckout{Repo: "user/repo"}}
+	if sha, err := github.GetBranchSum(context.Background(), "main"); err != nil || sha != "fake-sha" {
+		t.Fatalf("Expected SHA 'fake-sha', got '%s' (%v)", sha, err)
+	}
+	if *issued != 1 {
+		t.Fatalf("Expected one token for user/repo, but %d were issued", *issued)
+	}
+}
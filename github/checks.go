@@ -0,0 +1,160 @@
+package github
+
+import (
+	"context"
+	"strings"
+
+	"autopuller/source"
+)
+
+// CheckLastRun gathers the check runs, commit statuses and required workflow
+// runs reported for the commit.
+func (g *RealGitHubAPI) CheckLastRun(ctx context.Context, sha string, required []string) ([]source.CheckResult, []source.CheckResult, error) {
+	checks, err := g.listCheckRuns(ctx, sha)
+	if err != nil {
+		return nil, nil, err
+	}
+	statuses, err := g.listCommitStatuses(ctx, sha)
+	if err != nil {
+		return nil, nil, err
+	}
+	checks = append(checks, statuses...)
+
+	var workflows []source.CheckResult
+	if len(required) > 0 {
+		runs, err := g.listWorkflowRuns(ctx, sha)
+		if err != nil {
+			return nil, nil, err
+		}
+		workflows = requiredWorkflows(required, runs)
+		for _, workflow := range workflows {
+			source.Logger(ctx).Printf("Workflow %s for %s: %s", workflow.Name, sha, workflow.State)
+		}
+	}
+
+	return checks, workflows, nil
+}
+
+// workflowRun is a GitHub Actions workflow run for a commit.
+type workflowRun struct {
+	Name       string `json:"name"`
+	Path       string `json:"path"`
+	Status     string `json:"status"`
+	Conclusion string `json:"conclusion"`
+}
+
+// matches reports whether the run belongs to the workflow given by name or
+// file path (either .github/workflows/test.yml or test.yml).
+func (r workflowRun) matches(workflow string) bool {
+	// Runs of reusable workflows report the path with an @ref suffix
+	path := strings.SplitN(r.Path, "@", 2)[0]
+	return r.Name == workflow || path == workflow || strings.TrimPrefix(path, ".github/workflows/") == workflow
+}
+
+// requiredWorkflows resolves each required workflow to the state of its most
+// recent run. A workflow without any run for the commit is pending.
+func requiredWorkflows(required []string, runs []workflowRun) []source.CheckResult {
+	var results []source.CheckResult
+	for _, workflow := range required {
+		result := source.CheckResult{Name: workflow, State: source.CIPending}
+		// Runs are listed newest first
+		for _, run := range runs {
+			if !run.matches(workflow) {
+				continue
+			}
+			if run.Status == "completed" {
+				result.State = source.CIFailed
+				if run.Conclusion == "success" {
+					result.State = source.CIPassed
+				}
+			}
+			break
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// listWorkflowRuns lists the GitHub Actions workflow runs for the commit.
+func (g *RealGitHubAPI) listWorkflowRuns(ctx context.Context, sha string) ([]workflowRun, error) {
+	var runs []workflowRun
+	url := g.repoURL("/actions/runs?head_sha=" + sha + "&per_page=100")
+	for url != "" {
+		var page struct {
+			WorkflowRuns []workflowRun `json:"workflow_runs"`
+		}
+		next, err := g.getJSON(ctx, url, &page)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, page.WorkflowRuns...)
+		url = next
+	}
+	return runs, nil
+}
+
+// listCheckRuns lists the latest check run of each check for the commit.
+func (g *RealGitHubAPI) listCheckRuns(ctx context.Context, sha string) ([]source.CheckResult, error) {
+	var checks []source.CheckResult
+	url := g.repoURL("/commits/" + sha + "/check-runs?filter=latest&per_page=100")
+	for url != "" {
+		var page struct {
+			CheckRuns []struct {
+				Name       string `json:"name"`
+				Status     string `json:"status"`
+				Conclusion string `json:"conclusion"`
+			} `json:"check_runs"`
+		}
+		next, err := g.getJSON(ctx, url, &page)
+		if err != nil {
+			return nil, err
+		}
+		for _, run := range page.CheckRuns {
+			checks = append(checks, source.CheckResult{Name: run.Name, State: checkRunState(run.Status, run.Conclusion)})
+		}
+		url = next
+	}
+	return checks, nil
+}
+
+// checkRunState maps a check run's status and conclusion to a source.CIState.
+func checkRunState(status, conclusion string) source.CIState {
+	if status != "completed" {
+		return source.CIPending
+	}
+	switch conclusion {
+	case "success", "neutral", "skipped":
+		return source.CIPassed
+	}
+	return source.CIFailed
+}
+
+// listCommitStatuses lists the latest commit status of each context for the commit.
+func (g *RealGitHubAPI) listCommitStatuses(ctx context.Context, sha string) ([]source.CheckResult, error) {
+	var checks []source.CheckResult
+	url := g.repoURL("/commits/" + sha + "/status?per_page=100")
+	for url != "" {
+		var page struct {
+			Statuses []struct {
+				Context string `json:"context"`
+				State   string `json:"state"`
+			} `json:"statuses"`
+		}
+		next, err := g.getJSON(ctx, url, &page)
+		if err != nil {
+			return nil, err
+		}
+		for _, status := range page.Statuses {
+			state := source.CIFailed
+			switch status.State {
+			case "success":
+				state = source.CIPassed
+			case "pending":
+				state = source.CIPending
+			}
+			checks = append(checks, source.CheckResult{Name: status.Context, State: state})
+		}
+		url = next
+	}
+	return checks, nil
+}
@@ -0,0 +1,143 @@
+package github
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"autopuller/source"
+)
+
+func TestCheckLastRun_Success(t *testing.T) {
+	// Set up a fake GitHub API server reporting a paginated check run list and a commit status
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/repos/fake-repo/commits/fake-sha/check-runs":
+			if r.URL.Query().Get("page") == "" {
+				w.Header().Set("Link", `<`+ts.URL+`/repos/fake-repo/commits/fake-sha/check-runs?page=2>; rel="next"`)
+				w.Write([]byte(`{"check_runs": [{"name": "test", "status": "completed", "conclusion": "success"}]}`))
+				return
+			}
+			w.Write([]byte(`{"check_runs": [{"name": "lint", "status": "completed", "conclusion": "skipped"}]}`))
+		case "/repos/fake-repo/commits/fake-sha/status":
+			w.Write([]byte(`{"statuses": [{"context": "ci/jenkins", "state": "success"}]}`))
+		default:
+			t.Errorf("Unexpected request to '%s'", r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	// Set environment variables
+	os.Setenv("REPONAME", "fake-repo")
+	os.Setenv("GITHUBKEY", "fake-key")
+
+	// Set the environment variable to override the URL prefix with the test server URL
+	os.Setenv("GITHUB_URL_PREFIX", ts.URL+"/repos/")
+
+	// Create an instance of RealGitHubAPI
+	github := &RealGitHubAPI{}
+
+	// Call CheckLastRun and check the result
+	checks, workflows, err := github.CheckLastRun(context.Background(), "fake-sha", nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(checks) != 3 || len(workflows) != 0 {
+		t.Fatalf("Expected 3 checks and no workflows, got %v and %v", checks, workflows)
+	}
+	for _, check := range checks {
+		if check.State != source.CIPassed {
+			t.Fatalf("Expected %s to pass, got %s", check.Name, check.State)
+		}
+	}
+}
+
+func TestCheckLastRun_Failure(t *testing.T) {
+	// Server errors are retried, without waiting in tests
+	retryDelay := source.RetryDelay
+	source.RetryDelay = time.Millisecond
+	defer func() { source.RetryDelay = retryDelay }()
+
+	// Set up a fake GitHub API server
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "GitHub API failure", http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	os.Setenv("REPONAME", "fake-repo")
+	os.Setenv("GITHUB_URL_PREFIX", ts.URL+"/repos/")
+
+	github := &RealGitHubAPI{}
+	if _, _, err := github.CheckLastRun(context.Background(), "fake-sha", nil); err == nil {
+		t.Fatalf("Expected an error, but got nil")
+	}
+}
+
+func TestCheckLastRun_Workflows(t *testing.T) {
+	// Set up a fake GitHub API server where the docs workflow passed but the tests failed
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/repos/fake-repo/commits/fake-sha/check-runs", "/repos/fake-repo/commits/fake-sha/status":
+			w.Write([]byte(`{}`))
+		case "/repos/fake-repo/actions/runs":
+			if r.URL.Query().Get("head_sha") != "fake-sha" {
+				t.Errorf("Expected runs to be filtered by head_sha, got '%s'", r.URL.RawQuery)
+			}
+			w.Write([]byte(`{"workflow_runs": [
+				{"name": "Docs Lint", "path": ".github/workflows/docs.yml", "status": "completed", "conclusion": "success"},
+				{"name": "Go Test", "path": ".github/workflows/go-tests.yml", "status": "completed", "conclusion": "failure"}
+			]}`))
+		default:
+			t.Errorf("Unexpected request to '%s'", r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	os.Setenv("REPONAME", "fake-repo")
+	os.Setenv("GITHUB_URL_PREFIX", ts.URL+"/repos/")
+
+	github := &RealGitHubAPI{}
+
+	// Only the docs workflow is required
+	_, workflows, err := github.CheckLastRun(context.Background(), "fake-sha", []string{"Docs Lint"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(workflows) != 1 || workflows[0].State != source.CIPassed {
+		t.Fatalf("Expected the docs workflow to pass, got %v", workflows)
+	}
+
+	// The test workflow is required by file path as well
+	_, workflows, err = github.CheckLastRun(context.Background(), "fake-sha", []string{"Docs Lint", "go-tests.yml"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(workflows) != 2 || workflows[1].State != source.CIFailed {
+		t.Fatalf("Expected the test workflow to fail, got %v", workflows)
+	}
+}
+
+// TestRequiredWorkflows checks how workflow runs resolve to per-workflow results.
+func TestRequiredWorkflows(t *testing.T) {
+	runs := []workflowRun{
+		{Name: "Go Test", Path: ".github/workflows/go-tests.yml", Status: "in_progress"},
+		{Name: "Go Test", Path: ".github/workflows/go-tests.yml", Status: "completed", Conclusion: "failure"},
+		{Name: "Build", Path: ".github/workflows/build.yml", Status: "completed", Conclusion: "success"},
+	}
+	results := requiredWorkflows([]string{"Go Test", ".github/workflows/build.yml", "Deploy"}, runs)
+
+	want := []source.CIState{source.CIPending, source.CIPassed, source.CIPending}
+	for i, result := range results {
+		if result.State != want[i] {
+			t.Fatalf("Expected workflow %s to be %s, got %s", result.Name, want[i], result.State)
+		}
+	}
+}
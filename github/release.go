@@ -0,0 +1,76 @@
+package github
+
+import (
+	"context"
+
+	"autopuller/env"
+	"autopuller/source"
+)
+
+// ListReleases lists the published GitHub releases with RELEASE_MODE=releases,
+// and every tag in the repository otherwise.
+func (g *RealGitHubAPI) ListReleases(ctx context.Context) ([]source.ReleaseCandidate, error) {
+	if env.GetReleaseMode() == "releases" {
+		return g.listReleases(ctx)
+	}
+	return g.listTags(ctx)
+}
+
+// ResolveTag looks up the commit of a tag, which releases don't include.
+func (g *RealGitHubAPI) ResolveTag(ctx context.Context, tag string) (string, error) {
+	var commit struct {
+		Sha string `json:"sha"`
+	}
+	if _, err := g.getJSON(ctx, g.repoURL("/commits/"+tag), &commit); err != nil {
+		return "", err
+	}
+	return commit.Sha, nil
+}
+
+// listTags lists every tag in the repository along with its commit SHA.
+func (g *RealGitHubAPI) listTags(ctx context.Context) ([]source.ReleaseCandidate, error) {
+	var candidates []source.ReleaseCandidate
+	url := g.repoURL("/tags?per_page=100")
+	for url != "" {
+		var page []struct {
+			Name   string `json:"name"`
+			Commit struct {
+				Sha string `json:"sha"`
+			} `json:"commit"`
+		}
+		next, err := g.getJSON(ctx, url, &page)
+		if err != nil {
+			return nil, err
+		}
+		for _, tag := range page {
+			candidates = append(candidates, source.ReleaseCandidate{Tag: tag.Name, Sha: tag.Commit.Sha})
+		}
+		url = next
+	}
+	return candidates, nil
+}
+
+// listReleases lists the published (non-draft) GitHub releases of the repository.
+func (g *RealGitHubAPI) listReleases(ctx context.Context) ([]source.ReleaseCandidate, error) {
+	var candidates []source.ReleaseCandidate
+	url := g.repoURL("/releases?per_page=100")
+	for url != "" {
+		var page []struct {
+			TagName    string `json:"tag_name"`
+			Draft      bool   `json:"draft"`
+			Prerelease bool   `json:"prerelease"`
+		}
+		next, err := g.getJSON(ctx, url, &page)
+		if err != nil {
+			return nil, err
+		}
+		for _, release := range page {
+			if release.Draft {
+				continue
+			}
+			candidates = append(candidates, source.ReleaseCandidate{Tag: release.TagName, Prerelease: release.Prerelease})
+		}
+		url = next
+	}
+	return candidates, nil
+}
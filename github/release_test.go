@@ -0,0 +1,101 @@
+package github
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"autopuller/source"
+)
+
+// TestLatestRelease_Tags checks that the highest matching tag is picked across pages.
+func TestLatestRelease_Tags(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/fake-repo/tags" {
+			t.Errorf("Expected request to '/repos/fake-repo/tags', got '%s'", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("page") == "" {
+			w.Header().Set("Link", `<`+ts.URL+`/repos/fake-repo/tags?per_page=100&page=2>; rel="next"`)
+			w.Write([]byte(`[
+				{"name": "v1.5.0", "commit": {"sha": "sha-150"}},
+				{"name": "v1.4.3-rc.1", "commit": {"sha": "sha-143rc"}},
+				{"name": "nightly", "commit": {"sha": "sha-nightly"}}
+			]`))
+			return
+		}
+		w.Write([]byte(`[
+			{"name": "v1.4.2", "commit": {"sha": "sha-142"}},
+			{"name": "v1.3.9", "commit": {"sha": "sha-139"}}
+		]`))
+	}))
+	defer ts.Close()
+
+	os.Setenv("REPONAME", "fake-repo")
+	os.Setenv("GITHUB_URL_PREFIX", ts.URL+"/repos/")
+	os.Setenv("RELEASE_MODE", "tags")
+	defer os.Unsetenv("RELEASE_MODE")
+
+	github := &RealGitHubAPI{}
+
+	release, err := source.LatestRelease(context.Background(), github, "~1.4", false)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if release.Tag != "v1.4.2" || release.Sha != "sha-142" {
+		t.Fatalf("Expected v1.4.2 (sha-142), got %s (%s)", release.Tag, release.Sha)
+	}
+
+	// Prereleases are picked once allowed
+	release, err = source.LatestRelease(context.Background(), github, "~1.4", true)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if release.Tag != "v1.4.3-rc.1" {
+		t.Fatalf("Expected v1.4.3-rc.1, got %s", release.Tag)
+	}
+
+	// Nothing matches
+	if _, err := source.LatestRelease(context.Background(), github, ">=3", false); err == nil {
+		t.Fatalf("Expected an error when no release matches, but got nil")
+	}
+}
+
+// TestLatestRelease_Releases checks that drafts and flagged prereleases are skipped
+// and the commit is looked up from the tag.
+func TestLatestRelease_Releases(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/repos/fake-repo/releases":
+			w.Write([]byte(`[
+				{"tag_name": "v2.1.0", "draft": true},
+				{"tag_name": "v2.0.1", "prerelease": true},
+				{"tag_name": "v2.0.0"}
+			]`))
+		case "/repos/fake-repo/commits/v2.0.0":
+			w.Write([]byte(`{"sha": "sha-200"}`))
+		default:
+			t.Errorf("Unexpected request to '%s'", r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	os.Setenv("REPONAME", "fake-repo")
+	os.Setenv("GITHUB_URL_PREFIX", ts.URL+"/repos/")
+	os.Setenv("RELEASE_MODE", "releases")
+	defer os.Unsetenv("RELEASE_MODE")
+
+	github := &RealGitHubAPI{}
+	release, err := source.LatestRelease(context.Background(), github, "", false)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if release.Tag != "v2.0.0" || release.Sha != "sha-200" {
+		t.Fatalf("Expected v2.0.0 (sha-200), got %s (%s)", release.Tag, release.Sha)
+	}
+}
@@ -0,0 +1,186 @@
+package github
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"autopuller/source"
+)
+
+// client is shared by all GitHub API requests. Each request is made
+// conditional on a cached ETag, held back while rate limited, and
+// authenticated, so installation tokens are refreshed without the callers
+// noticing.
+var client = newClient()
+
+// newClient creates a client with empty response cache and rate-limit state.
+func newClient() *http.Client {
+	return &http.Client{Transport: &cachingTransport{next: &rateLimitTransport{next: authTransport{}, now: time.Now}}}
+}
+
+// maxCachedResponses bounds how many responses are kept for conditional requests.
+const maxCachedResponses = 256
+
+// cachingTransport makes GET requests conditional on the ETag of the last
+// response for the same URL and replays that response on a 304. GitHub
+// doesn't count 304 responses against the rate limit, so polling an
+// unchanged branch or run list is free.
+type cachingTransport struct {
+	next    http.RoundTripper
+	mu      sync.Mutex
+	entries map[string]*cachedResponse
+	order   []string
+}
+
+// cachedResponse is a response body with the ETag and headers it came with.
+type cachedResponse struct {
+	etag   string
+	header http.Header
+	body   []byte
+}
+
+// RoundTrip sends the request, conditionally when a cached response exists.
+func (c *cachingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return c.next.RoundTrip(req)
+	}
+
+	key := req.URL.String()
+	cached := c.get(key)
+	if cached != nil {
+		req = req.Clone(req.Context())
+		req.Header.Set("If-None-Match", cached.etag)
+	}
+
+	resp, err := c.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		resp.Body.Close()
+		return cached.response(req), nil
+	}
+	if etag := resp.Header.Get("ETag"); resp.StatusCode == http.StatusOK && etag != "" {
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		c.put(key, &cachedResponse{etag: etag, header: resp.Header.Clone(), body: body})
+		resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	return resp, nil
+}
+
+// get returns the cached response for the URL, if any.
+func (c *cachingTransport) get(key string) *cachedResponse {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.entries[key]
+}
+
+// put caches the response for the URL, evicting the oldest entry when full.
+func (c *cachingTransport) put(key string, cached *cachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = map[string]*cachedResponse{}
+	}
+	if _, ok := c.entries[key]; !ok {
+		c.order = append(c.order, key)
+	}
+	c.entries[key] = cached
+	for len(c.order) > maxCachedResponses {
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+}
+
+// response rebuilds the cached response for the request.
+func (r *cachedResponse) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        r.header.Clone(),
+		Body:          ioutil.NopCloser(bytes.NewReader(r.body)),
+		ContentLength: int64(len(r.body)),
+		Request:       req,
+	}
+}
+
+// rateLimitTransport follows GitHub's rate-limit headers. Once the limit is
+// exhausted, or a secondary limit asks to retry later, requests fail with a
+// source.RateLimitError without being sent until the limit resets.
+type rateLimitTransport struct {
+	next  http.RoundTripper
+	mu    sync.Mutex
+	until time.Time
+	now   func() time.Time
+}
+
+// RoundTrip sends the request unless requests are paused.
+func (l *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if until := l.pausedUntil(); !until.IsZero() {
+		return nil, &source.RateLimitError{Until: until}
+	}
+
+	resp, err := l.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	until := l.limitFrom(resp)
+	if until.IsZero() {
+		return resp, nil
+	}
+	l.mu.Lock()
+	l.until = until
+	l.mu.Unlock()
+	source.Logger(req.Context()).Printf("GitHub rate limit reached, pausing requests until %s", until.Format(time.RFC3339))
+
+	// The request that used up the limit still succeeded
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return resp, nil
+	}
+	resp.Body.Close()
+	return nil, &source.RateLimitError{Until: until}
+}
+
+// pausedUntil returns when requests may resume, or the zero time if they may
+// be sent now.
+func (l *rateLimitTransport) pausedUntil() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.now().Before(l.until) {
+		return l.until
+	}
+	return time.Time{}
+}
+
+// limitFrom returns until when the response asks requests to pause, or the
+// zero time. Retry-After comes with secondary limits; an exhausted primary
+// limit reports X-RateLimit-Remaining 0 and its reset as a Unix time.
+func (l *rateLimitTransport) limitFrom(resp *http.Response) time.Time {
+	limited := resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && limited {
+		return l.now().Add(time.Duration(seconds) * time.Second)
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			return time.Unix(reset, 0)
+		}
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		// No hint given, GitHub suggests waiting at least a minute
+		return l.now().Add(time.Minute)
+	}
+	return time.Time{}
+}
@@ -0,0 +1,128 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"autopuller/source"
+)
+
+// TestConditionalRequests tests that an unchanged branch is answered from the
+// cache after a 304.
+func TestConditionalRequests(t *testing.T) {
+	client = newClient()
+	defer func() { client = newClient() }()
+
+	full, notModified := 0, 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"abc"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		full++
+		w.Header().Set("ETag", `"abc"`)
+		w.Write([]byte(`{"sha": "fake-sha"}`))
+	}))
+	defer ts.Close()
+
+	os.Setenv("REPONAME", "fake-repo")
+	os.Setenv("GITHUB_URL_PREFIX", ts.URL+"/repos/")
+
+	github := &RealGitHubAPI{}
+	for i := 0; i < 3; i++ {
+		sha, err := github.GetBranchSum(context.Background(), "master")
+		if err != nil || sha != "fake-sha" {
+			t.Fatalf("Expected SHA 'fake-sha', got '%s' (%v)", sha, err)
+		}
+	}
+	if full != 1 || notModified != 2 {
+		t.Fatalf("Expected 1 full and 2 conditional responses, got %d and %d", full, notModified)
+	}
+}
+
+// TestRateLimit tests that requests pause until the limit resets once GitHub
+// reports it exhausted, or asks to retry later.
+func TestRateLimit(t *testing.T) {
+	reset := time.Now().Add(time.Hour).Truncate(time.Second)
+	tests := []struct {
+		name   string
+		status int
+		header map[string]string
+		until  time.Time
+	}{
+		{name: "primary limit", status: http.StatusForbidden, header: map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": fmt.Sprint(reset.Unix())}, until: reset},
+		{name: "secondary limit", status: http.StatusTooManyRequests, header: map[string]string{"Retry-After": "30"}, until: time.Now().Add(30 * time.Second)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client = newClient()
+			defer func() { client = newClient() }()
+
+			requests := 0
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				for key, value := range tt.header {
+					w.Header().Set(key, value)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer ts.Close()
+
+			os.Setenv("REPONAME", "fake-repo")
+			os.Setenv("GITHUB_URL_PREFIX", ts.URL+"/repos/")
+
+			github := &RealGitHubAPI{}
+			for i := 0; i < 2; i++ {
+				_, err := github.GetBranchSum(context.Background(), "master")
+				var rateLimited *source.RateLimitError
+				if !errors.As(err, &rateLimited) {
+					t.Fatalf("Expected a rate limit error, got %v", err)
+				}
+				if diff := rateLimited.Until.Sub(tt.until); diff < -time.Second || diff > time.Second {
+					t.Fatalf("Expected requests paused until %s, got %s", tt.until, rateLimited.Until)
+				}
+			}
+			if requests != 1 {
+				t.Fatalf("Expected requests to stop while limited, but got %d", requests)
+			}
+		})
+	}
+}
+
+// TestRateLimit_Forbidden tests that a 403 unrelated to the rate limit is an
+// ordinary error and doesn't pause requests.
+func TestRateLimit_Forbidden(t *testing.T) {
+	client = newClient()
+	defer func() { client = newClient() }()
+
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("X-RateLimit-Remaining", "4999")
+		http.Error(w, "Resource not accessible by integration", http.StatusForbidden)
+	}))
+	defer ts.Close()
+
+	os.Setenv("REPONAME", "fake-repo")
+	os.Setenv("GITHUB_URL_PREFIX", ts.URL+"/repos/")
+
+	github := &RealGitHubAPI{}
+	for i := 0; i < 2; i++ {
+		_, err := github.GetBranchSum(context.Background(), "master")
+		var rateLimited *source.RateLimitError
+		if err == nil || errors.As(err, &rateLimited) {
+			t.Fatalf("Expected a plain error, got %v", err)
+		}
+	}
+	if requests != 2 {
+		t.Fatalf("Expected both requests to be sent, but got %d", requests)
+	}
+}
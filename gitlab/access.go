@@ -0,0 +1,29 @@
+package gitlab
+
+import (
+	"context"
+	"fmt"
+
+	"autopuller/env"
+	"autopuller/source"
+)
+
+// CheckAccess confirms the followed project can be read with GITLABKEY, or
+// without a token if none is set, and describes its visibility. GitLab hides
+// a project the token can't read behind a 404.
+func (g *RealGitLabAPI) CheckAccess(ctx context.Context) (string, error) {
+	if g.RepoName() == "" {
+		return "", fmt.Errorf("repo (REPONAME) is not set")
+	}
+
+	var project struct {
+		Visibility string `json:"visibility"`
+	}
+	if _, err := getJSON(ctx, g.projectURL(""), &project); err != nil {
+		return "", source.AccessError(g.RepoName(), err)
+	}
+	if env.Current().GitLab.Key == "" {
+		return fmt.Sprintf("%s is %s and readable without a token", g.RepoName(), project.Visibility), nil
+	}
+	return fmt.Sprintf("%s is %s and readable with the token", g.RepoName(), project.Visibility), nil
+}
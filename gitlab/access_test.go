@@ -0,0 +1,30 @@
+package gitlab
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// TestCheckAccess checks that a readable project is described and that a
+// project hidden from the token is explained.
+func TestCheckAccess(t *testing.T) {
+	ts := newFakeGitLab(t, map[string]string{
+		"/api/v4/projects/group%2Fproject": `{"visibility": "private"}`,
+	})
+	defer ts.Close()
+
+	desc, err := (&RealGitLabAPI{}).CheckAccess(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if desc != "group/project is private and readable with the token" {
+		t.Fatalf("Expected the project to be described, got '%s'", desc)
+	}
+
+	gitlab := &RealGitLabAPI{}
+	gitlab.Repo = "group/other"
+	if _, err := gitlab.CheckAccess(context.Background()); err == nil || !strings.Contains(err.Error(), "group/other not found or not visible") {
+		t.Fatalf("Expected a hidden project to be explained, got %v", err)
+	}
+}
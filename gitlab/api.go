@@ -0,0 +1,108 @@
+package gitlab
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"autopuller/env"
+	"autopuller/source"
+)
+
+// RealGitLabAPI is the GitLab implementation of source.Source, using the
+// commits, pipelines and compare APIs of GitLab.com or a self-hosted instance.
+type RealGitLabAPI struct {
+	source.GitCheckout
+}
+
+// projectURL builds an API URL for a path under the followed project.
+// The repository name is the project path (group/project) and GITLAB_URL the
+// instance.
+func (g *RealGitLabAPI) projectURL(path string) string {
+	return strings.TrimSuffix(env.Current().GitLab.URL, "/") + "/api/v4/projects/" + url.PathEscape(g.RepoName()) + path
+}
+
+// getJSON performs an authenticated GET request and decodes the JSON response
+// into result. It returns the URL of the next page, if any.
+func getJSON(ctx context.Context, url string, result interface{}) (string, error) {
+	header := http.Header{}
+	if gitlabkey := env.Current().GitLab.Key; gitlabkey != "" {
+		header.Set("PRIVATE-TOKEN", gitlabkey)
+	}
+	return source.GetJSON(ctx, url, header, result)
+}
+
+// GetBranchSum fetches the latest commit SHA from GitLab for the given branch.
+func (g *RealGitLabAPI) GetBranchSum(ctx context.Context, branch string) (string, error) {
+	var commit struct {
+		ID string `json:"id"`
+	}
+	if _, err := getJSON(ctx, g.projectURL("/repository/commits/"+url.PathEscape(branch)), &commit); err != nil {
+		return "", err
+	}
+	return commit.ID, nil
+}
+
+// compareResult is the response of the repository compare endpoint.
+type compareResult struct {
+	Commits []struct {
+		ID        string   `json:"id"`
+		ParentIDs []string `json:"parent_ids"`
+	} `json:"commits"`
+	Diffs []struct {
+		OldPath     string `json:"old_path"`
+		NewPath     string `json:"new_path"`
+		DeletedFile bool   `json:"deleted_file"`
+	} `json:"diffs"`
+	CompareTimeout bool `json:"compare_timeout"`
+}
+
+// compare compares two SHAs.
+func (g *RealGitLabAPI) compare(ctx context.Context, oldSha, newSha string) (compareResult, error) {
+	var result compareResult
+	query := url.Values{"from": {oldSha}, "to": {newSha}, "straight": {"false"}}
+	_, err := getJSON(ctx, g.projectURL("/repository/compare?"+query.Encode()), &result)
+	return result, err
+}
+
+// localDifferences lists changed files with git, overridable in tests.
+var localDifferences = source.LocalDifferences
+
+// CheckDifferences compares two SHAs and returns a list of changed files.
+// If GitLab timed out computing the comparison the list is computed locally
+// with git diff instead.
+func (g *RealGitLabAPI) CheckDifferences(ctx context.Context, oldSha, newSha string) ([]string, error) {
+	result, err := g.compare(ctx, oldSha, newSha)
+	if err != nil {
+		return nil, err
+	}
+	if result.CompareTimeout {
+		source.Logger(ctx).Printf("Compare of %s...%s timed out, using git diff instead", oldSha, newSha)
+		return localDifferences(ctx, g.RepoDir(), oldSha, newSha)
+	}
+
+	var diffs []string
+	for _, diff := range result.Diffs {
+		if diff.DeletedFile {
+			diffs = append(diffs, diff.OldPath)
+			continue
+		}
+		diffs = append(diffs, diff.NewPath)
+	}
+	return diffs, nil
+}
+
+// ListCommits lists the SHAs of the commits after oldSha up to and including
+// newSha on the first-parent line of newSha, oldest first.
+func (g *RealGitLabAPI) ListCommits(ctx context.Context, oldSha, newSha string) ([]string, error) {
+	result, err := g.compare(ctx, oldSha, newSha)
+	if err != nil {
+		return nil, err
+	}
+	parents := map[string][]string{}
+	for _, commit := range result.Commits {
+		parents[commit.ID] = commit.ParentIDs
+	}
+	return source.FirstParentLine(newSha, parents), nil
+}
@@ -0,0 +1,188 @@
+package gitlab
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"autopuller/source"
+)
+
+// newFakeGitLab starts an httptest stand-in for the GitLab API serving the
+// given responses by escaped path, answering 404 to anything else, and points the backend at it.
+func newFakeGitLab(t *testing.T, responses map[string]string) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("PRIVATE-TOKEN") != "fake-key" {
+			t.Errorf("Expected the PRIVATE-TOKEN header to be set")
+		}
+		body, ok := responses[r.URL.EscapedPath()]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+
+	os.Setenv("GITLAB_URL", ts.URL+"/")
+	os.Setenv("GITLABKEY", "fake-key")
+	os.Setenv("REPONAME", "group/project")
+	return ts
+}
+
+func TestGetBranchSum_Success(t *testing.T) {
+	ts := newFakeGitLab(t, map[string]string{
+		"/api/v4/projects/group%2Fproject/repository/commits/main": `{"id": "fake-sha"}`,
+	})
+	defer ts.Close()
+
+	gitlab := &RealGitLabAPI{}
+	sha, err := gitlab.GetBranchSum(context.Background(), "main")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if sha != "fake-sha" {
+		t.Fatalf("Expected SHA 'fake-sha', got '%s'", sha)
+	}
+}
+
+func TestGetBranchSum_Failure(t *testing.T) {
+	ts := newFakeGitLab(t, map[string]string{})
+	defer ts.Close()
+
+	gitlab := &RealGitLabAPI{}
+	if _, err := gitlab.GetBranchSum(context.Background(), "main"); err == nil {
+		t.Fatalf("Expected an error, but got nil")
+	}
+}
+
+func TestCheckDifferences_Success(t *testing.T) {
+	ts := newFakeGitLab(t, map[string]string{
+		"/api/v4/projects/group%2Fproject/repository/compare": `{
+			"commits": [
+				{"id": "sha1", "parent_ids": ["oldSha"]},
+				{"id": "side", "parent_ids": ["oldSha"]},
+				{"id": "newSha", "parent_ids": ["sha1", "side"]}
+			],
+			"diffs": [
+				{"old_path": "file1.txt", "new_path": "file1.txt"},
+				{"old_path": "old.txt", "new_path": "renamed.txt"},
+				{"old_path": "gone.txt", "new_path": "gone.txt", "deleted_file": true}
+			]
+		}`,
+	})
+	defer ts.Close()
+
+	gitlab := &RealGitLabAPI{}
+	diffs, err := gitlab.CheckDifferences(context.Background(), "oldSha", "newSha")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expectedDiffs := []string{"file1.txt", "renamed.txt", "gone.txt"}
+	if len(diffs) != len(expectedDiffs) {
+		t.Fatalf("Expected %d differences, got %d", len(expectedDiffs), len(diffs))
+	}
+	for i, diff := range diffs {
+		if diff != expectedDiffs[i] {
+			t.Fatalf("Expected difference '%s', got '%s'", expectedDiffs[i], diff)
+		}
+	}
+
+	commits, err := gitlab.ListCommits(context.Background(), "oldSha", "newSha")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(commits) != 2 || commits[0] != "sha1" || commits[1] != "newSha" {
+		t.Fatalf("Expected [sha1 newSha], got %v", commits)
+	}
+}
+
+func TestCheckDifferences_Timeout(t *testing.T) {
+	ts := newFakeGitLab(t, map[string]string{
+		"/api/v4/projects/group%2Fproject/repository/compare": `{"diffs": [], "compare_timeout": true}`,
+	})
+	defer ts.Close()
+
+	// The local git diff lists the changed files instead
+	originalLocalDifferences := localDifferences
+	defer func() {
+		localDifferences = originalLocalDifferences
+	}()
+	localDifferences = func(ctx context.Context, repoDir, oldSha, newSha string) ([]string, error) {
+		return []string{"local1.txt"}, nil
+	}
+
+	gitlab := &RealGitLabAPI{}
+	diffs, err := gitlab.CheckDifferences(context.Background(), "oldSha", "newSha")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(diffs) != 1 || diffs[0] != "local1.txt" {
+		t.Fatalf("Expected the local diff [local1.txt], got %v", diffs)
+	}
+}
+
+func TestCheckLastRun(t *testing.T) {
+	ts := newFakeGitLab(t, map[string]string{
+		"/api/v4/projects/group%2Fproject/pipelines": `[{"id": 42, "status": "success"}]`,
+		"/api/v4/projects/group%2Fproject/pipelines/42/jobs": `[
+			{"name": "test", "status": "success"},
+			{"name": "lint", "status": "failed", "allow_failure": true},
+			{"name": "deploy", "status": "manual"}
+		]`,
+	})
+	defer ts.Close()
+
+	gitlab := &RealGitLabAPI{}
+	checks, _, err := gitlab.CheckLastRun(context.Background(), "fake-sha", nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(checks) != 3 {
+		t.Fatalf("Expected 3 checks, got %v", checks)
+	}
+	// Jobs allowed to fail and manual jobs don't block a deploy
+	for _, check := range checks {
+		if check.State != source.CIPassed {
+			t.Fatalf("Expected %s to pass, got %s", check.Name, check.State)
+		}
+	}
+}
+
+func TestCheckLastRun_NoPipeline(t *testing.T) {
+	ts := newFakeGitLab(t, map[string]string{
+		"/api/v4/projects/group%2Fproject/pipelines": `[]`,
+	})
+	defer ts.Close()
+
+	gitlab := &RealGitLabAPI{}
+	checks, _, err := gitlab.CheckLastRun(context.Background(), "fake-sha", nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(checks) != 0 {
+		t.Fatalf("Expected no checks, got %v", checks)
+	}
+}
+
+func TestLatestRelease(t *testing.T) {
+	ts := newFakeGitLab(t, map[string]string{
+		"/api/v4/projects/group%2Fproject/repository/tags": `[
+			{"name": "v1.5.0", "commit": {"id": "sha-150"}},
+			{"name": "v1.4.2", "commit": {"id": "sha-142"}}
+		]`,
+	})
+	defer ts.Close()
+
+	gitlab := &RealGitLabAPI{}
+	release, err := source.LatestRelease(context.Background(), gitlab, "~1.4", false)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if release.Tag != "v1.4.2" || release.Sha != "sha-142" {
+		t.Fatalf("Expected v1.4.2 (sha-142), got %s (%s)", release.Tag, release.Sha)
+	}
+}
@@ -0,0 +1,57 @@
+package gitlab
+
+import (
+	"context"
+	"fmt"
+
+	"autopuller/source"
+)
+
+// CheckLastRun gathers the jobs of the most recent pipeline for the commit.
+func (g *RealGitLabAPI) CheckLastRun(ctx context.Context, sha string, workflows []string) ([]source.CheckResult, []source.CheckResult, error) {
+	// Pipelines are listed newest first
+	var pipelines []struct {
+		ID     int    `json:"id"`
+		Status string `json:"status"`
+	}
+	if _, err := getJSON(ctx, g.projectURL("/pipelines?per_page=1&sha="+sha), &pipelines); err != nil {
+		return nil, nil, err
+	}
+
+	var checks []source.CheckResult
+	if len(pipelines) > 0 {
+		url := g.projectURL(fmt.Sprintf("/pipelines/%d/jobs?per_page=100", pipelines[0].ID))
+		for url != "" {
+			var page []struct {
+				Name         string `json:"name"`
+				Status       string `json:"status"`
+				AllowFailure bool   `json:"allow_failure"`
+			}
+			next, err := getJSON(ctx, url, &page)
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, job := range page {
+				checks = append(checks, source.CheckResult{Name: job.Name, State: jobState(job.Status, job.AllowFailure)})
+			}
+			url = next
+		}
+	}
+
+	return checks, nil, nil
+}
+
+// jobState maps a GitLab job status to a CIState. Jobs allowed to fail and
+// manual jobs that were never started don't block a deploy.
+func jobState(status string, allowFailure bool) source.CIState {
+	switch status {
+	case "success", "skipped", "manual":
+		return source.CIPassed
+	case "failed", "canceled":
+		if allowFailure {
+			return source.CIPassed
+		}
+		return source.CIFailed
+	}
+	return source.CIPending
+}
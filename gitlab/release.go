@@ -0,0 +1,46 @@
+package gitlab
+
+import (
+	"context"
+
+	"autopuller/env"
+	"autopuller/source"
+)
+
+// ListReleases lists the GitLab releases that are already out with
+// RELEASE_MODE=releases, and every tag in the project otherwise.
+func (g *RealGitLabAPI) ListReleases(ctx context.Context) ([]source.ReleaseCandidate, error) {
+	var candidates []source.ReleaseCandidate
+	url := g.projectURL("/repository/tags?per_page=100")
+	if env.GetReleaseMode() == "releases" {
+		url = g.projectURL("/releases?per_page=100")
+	}
+	for url != "" {
+		// Tags and releases both carry the tagged commit
+		var page []struct {
+			Name     string `json:"name"`
+			TagName  string `json:"tag_name"`
+			Upcoming bool   `json:"upcoming_release"`
+			Commit   struct {
+				ID string `json:"id"`
+			} `json:"commit"`
+		}
+		next, err := getJSON(ctx, url, &page)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range page {
+			if entry.Upcoming {
+				continue
+			}
+			tag := entry.TagName
+			if tag == "" {
+				tag = entry.Name
+			}
+			candidates = append(candidates, source.ReleaseCandidate{Tag: tag, Sha: entry.Commit.ID})
+		}
+		url = next
+	}
+
+	return candidates, nil
+}
@@ -0,0 +1,72 @@
+package pathfilter
+
+import (
+	"path"
+	"strings"
+)
+
+// Filter decides which changed files are runtime files that need the
+// containers to be rebuilt, using include and exclude glob rules.
+type Filter struct {
+	// Include lists the globs a file must match; empty includes every file.
+	Include []string
+	// Exclude lists the globs of files that never trigger a rebuild.
+	Exclude []string
+}
+
+// Match reports whether a changed file needs a rebuild, along with the rule
+// that decided it. Exclude rules win over include rules.
+func (f Filter) Match(file string) (bool, string) {
+	for _, pattern := range f.Exclude {
+		if MatchGlob(pattern, file) {
+			return false, "exclude " + pattern
+		}
+	}
+	if len(f.Include) == 0 {
+		return true, "default include"
+	}
+	for _, pattern := range f.Include {
+		if MatchGlob(pattern, file) {
+			return true, "include " + pattern
+		}
+	}
+	return false, "no include rule matched"
+}
+
+// MatchGlob matches a slash-separated file path against a glob. Besides the
+// path.Match syntax, `**` matches any number of directories, a pattern
+// without a slash matches the file name at any depth (*.md) and a trailing
+// slash matches everything below a directory (docs/).
+func MatchGlob(pattern, name string) bool {
+	pattern = strings.TrimPrefix(pattern, "/")
+	name = strings.TrimPrefix(name, "/")
+	if strings.HasSuffix(pattern, "/") {
+		pattern += "**"
+	}
+	if !strings.Contains(pattern, "/") {
+		pattern = "**/" + pattern
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+// matchSegments matches path segments, expanding ** to zero or more segments.
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
@@ -0,0 +1,56 @@
+package pathfilter
+
+import "testing"
+
+// TestMatchGlob checks glob matching against file paths.
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{pattern: "*.md", name: "README.md", want: true},
+		{pattern: "*.md", name: "docs/guide/intro.md", want: true},
+		{pattern: "*.md", name: "main.go", want: false},
+		{pattern: "docs/**", name: "docs/guide/intro.md", want: true},
+		{pattern: "docs/**", name: "src/docs/intro.md", want: false},
+		{pattern: "docs/", name: "docs/intro.md", want: true},
+		{pattern: ".github/**", name: ".github/workflows/go-tests.yml", want: true},
+		{pattern: "cmd/*.go", name: "cmd/main.go", want: true},
+		{pattern: "cmd/*.go", name: "cmd/sub/main.go", want: false},
+		{pattern: "**/testdata/**", name: "github/testdata/repo/HEAD", want: true},
+		{pattern: "/go.mod", name: "go.mod", want: true},
+	}
+	for _, tt := range tests {
+		if got := MatchGlob(tt.pattern, tt.name); got != tt.want {
+			t.Fatalf("MatchGlob(%q, %q): expected %v, got %v", tt.pattern, tt.name, tt.want, got)
+		}
+	}
+}
+
+// TestFilterMatch checks how include and exclude rules combine.
+func TestFilterMatch(t *testing.T) {
+	filter := Filter{
+		Include: []string{"cmd/**", "go.mod"},
+		Exclude: []string{"*_test.go"},
+	}
+	tests := []struct {
+		name string
+		want bool
+		rule string
+	}{
+		{name: "cmd/main.go", want: true, rule: "include cmd/**"},
+		{name: "cmd/main_test.go", want: false, rule: "exclude *_test.go"},
+		{name: "README.md", want: false, rule: "no include rule matched"},
+	}
+	for _, tt := range tests {
+		got, rule := filter.Match(tt.name)
+		if got != tt.want || rule != tt.rule {
+			t.Fatalf("Match(%q): expected %v (%s), got %v (%s)", tt.name, tt.want, tt.rule, got, rule)
+		}
+	}
+
+	if ok, _ := (Filter{}).Match("anything"); !ok {
+		t.Fatalf("Expected an empty filter to include every file")
+	}
+}
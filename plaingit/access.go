@@ -0,0 +1,12 @@
+package plaingit
+
+import "context"
+
+// CheckAccess confirms origin can be listed with the checkout's own git
+// credentials, such as its SSH key or credential helper.
+func (g *RealGitAPI) CheckAccess(ctx context.Context) (string, error) {
+	if _, err := g.lsRemote(ctx, "HEAD"); err != nil {
+		return "", err
+	}
+	return "origin is readable with the checkout's git credentials", nil
+}
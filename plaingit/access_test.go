@@ -0,0 +1,24 @@
+package plaingit
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestCheckAccess checks that a reachable origin passes and a missing one fails.
+func TestCheckAccess(t *testing.T) {
+	f := newFixture(t)
+	defer f.cleanup()
+
+	git := &RealGitAPI{}
+	if _, err := git.CheckAccess(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	os.RemoveAll(filepath.Join(f.root, "remote.git"))
+	if _, err := git.CheckAccess(context.Background()); err == nil {
+		t.Fatalf("Expected an error for a missing origin, but got nil")
+	}
+}
@@ -0,0 +1,74 @@
+package plaingit
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"autopuller/env"
+	"autopuller/source"
+)
+
+// exitPending is the exit code (EX_TEMPFAIL) a GIT_CI_COMMAND uses to report
+// that CI hasn't finished yet.
+const exitPending = 75
+
+// CheckLastRun asks the CI gate selected by GIT_CI_GATE about the commit:
+//   - "none" (default) lets every commit through.
+//   - "marker" passes once the file GIT_CI_MARKER exists, with {sha} replaced
+//     by the commit, and is pending until then.
+//   - "command" runs GIT_CI_COMMAND with the commit in $SHA; exit code 0
+//     passes, 75 is pending and anything else fails.
+func (g *RealGitAPI) CheckLastRun(ctx context.Context, sha string, workflows []string) ([]source.CheckResult, []source.CheckResult, error) {
+	var check source.CheckResult
+	switch gate := env.Current().Git.CIGate; gate {
+	case "", "none":
+		check = source.CheckResult{Name: "none", State: source.CIPassed}
+	case "marker":
+		check = markerCheck(sha)
+	case "command":
+		var err error
+		check, err = g.commandCheck(ctx, sha)
+		if err != nil {
+			return nil, nil, err
+		}
+	default:
+		return nil, nil, fmt.Errorf("unknown GIT_CI_GATE %q", gate)
+	}
+
+	return []source.CheckResult{check}, nil, nil
+}
+
+// markerCheck checks for the marker file of the commit.
+func markerCheck(sha string) source.CheckResult {
+	check := source.CheckResult{Name: "marker", State: source.CIPending}
+	if _, err := os.Stat(strings.Replace(env.Current().Git.CIMarker, "{sha}", sha, -1)); err == nil {
+		check.State = source.CIPassed
+	}
+	return check
+}
+
+// commandCheck runs the gate command for the commit.
+func (g *RealGitAPI) commandCheck(ctx context.Context, sha string) (source.CheckResult, error) {
+	check := source.CheckResult{Name: "command", State: source.CIPassed}
+	cmd := execCommandContext(ctx, "bash", "-c", env.Current().Git.CICommand)
+	cmd.Dir = g.RepoDir()
+	cmd.Env = append(os.Environ(), "SHA="+sha)
+	output, err := cmd.CombinedOutput()
+	if err == nil {
+		return check, nil
+	}
+
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		return check, fmt.Errorf("failed to run GIT_CI_COMMAND: %v", err)
+	}
+	source.Logger(ctx).Printf("GIT_CI_COMMAND exited with %d: %s", exitErr.ExitCode(), output)
+	check.State = source.CIFailed
+	if exitErr.ExitCode() == exitPending {
+		check.State = source.CIPending
+	}
+	return check, nil
+}
@@ -0,0 +1,107 @@
+package plaingit
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"autopuller/source"
+)
+
+// RealGitAPI is the source.Source implementation for any git remote, using
+// only git itself against the `origin` remote of the checkout. It needs no
+// hosting API, so CI results come from the gate configured with GIT_CI_GATE.
+type RealGitAPI struct {
+	source.GitCheckout
+}
+
+// Define function variables that can be overridden in tests
+var execCommandContext = exec.CommandContext
+
+// gitOutput runs git inside the checkout and returns its trimmed output. The
+// checkout is trusted for this command only, like source's git commands.
+func (g *RealGitAPI) gitOutput(ctx context.Context, args ...string) (string, error) {
+	cmd := execCommandContext(ctx, "git", append([]string{"-c", "safe.directory=" + g.RepoDir()}, args...)...)
+	cmd.Dir = g.RepoDir()
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			source.Logger(ctx).Printf("Output: %s", exitErr.Stderr)
+		}
+		return "", fmt.Errorf("failed to run git command %s: %v", args, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// lsRemote lists the refs of origin matching the patterns as ref name to SHA.
+func (g *RealGitAPI) lsRemote(ctx context.Context, patterns ...string) (map[string]string, error) {
+	output, err := g.gitOutput(ctx, append([]string{"ls-remote", "origin"}, patterns...)...)
+	if err != nil {
+		return nil, err
+	}
+	refs := map[string]string{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			refs[fields[1]] = fields[0]
+		}
+	}
+	return refs, nil
+}
+
+// GetBranchSum fetches the latest commit SHA of the branch with git ls-remote.
+func (g *RealGitAPI) GetBranchSum(ctx context.Context, branch string) (string, error) {
+	refs, err := g.lsRemote(ctx, "refs/heads/"+branch)
+	if err != nil {
+		return "", err
+	}
+	sha, ok := refs["refs/heads/"+branch]
+	if !ok {
+		return "", fmt.Errorf("branch %s not found on origin", branch)
+	}
+	return sha, nil
+}
+
+// CheckDifferences fetches from origin and lists the files changed between
+// two SHAs with git diff.
+func (g *RealGitAPI) CheckDifferences(ctx context.Context, oldSha, newSha string) ([]string, error) {
+	return source.LocalDifferences(ctx, g.RepoDir(), oldSha, newSha)
+}
+
+// ListCommits fetches from origin and lists the SHAs of the commits after
+// oldSha up to and including newSha on the first-parent line of newSha,
+// oldest first.
+func (g *RealGitAPI) ListCommits(ctx context.Context, oldSha, newSha string) ([]string, error) {
+	if _, err := g.gitOutput(ctx, "fetch", "--tags", "origin"); err != nil {
+		return nil, err
+	}
+	output, err := g.gitOutput(ctx, "rev-list", "--reverse", "--first-parent", oldSha+".."+newSha)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(output), nil
+}
+
+// ListReleases lists the tags on origin. Plain git has no releases, so both
+// release modes consider tags.
+func (g *RealGitAPI) ListReleases(ctx context.Context) ([]source.ReleaseCandidate, error) {
+	refs, err := g.lsRemote(ctx, "refs/tags/*")
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []source.ReleaseCandidate
+	for ref, sha := range refs {
+		if !strings.HasPrefix(ref, "refs/tags/") || strings.HasSuffix(ref, "^{}") {
+			continue
+		}
+		// Annotated tags point at a tag object; the peeled ref has the commit
+		if peeled, ok := refs[ref+"^{}"]; ok {
+			sha = peeled
+		}
+		candidates = append(candidates, source.ReleaseCandidate{Tag: strings.TrimPrefix(ref, "refs/tags/"), Sha: sha})
+	}
+
+	return candidates, nil
+}
@@ -0,0 +1,238 @@
+package plaingit
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"autopuller/source"
+)
+
+// fixture is a local bare repository with a working clone to push from and
+// a checkout (REPODIR) that autopuller follows.
+type fixture struct {
+	root     string
+	work     string
+	checkout string
+	commits  int
+}
+
+// newFixture creates the repositories in a temp dir, with HOME pointing there
+// so git's global config isn't touched.
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	root, err := ioutil.TempDir("", "plaingit")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	env := map[string]string{
+		"HOME":                root,
+		"GIT_AUTHOR_NAME":     "Test",
+		"GIT_AUTHOR_EMAIL":    "test@example.com",
+		"GIT_COMMITTER_NAME":  "Test",
+		"GIT_COMMITTER_EMAIL": "test@example.com",
+	}
+	for key, value := range env {
+		os.Setenv(key, value)
+	}
+
+	f := &fixture{root: root, work: filepath.Join(root, "work"), checkout: filepath.Join(root, "repo")}
+	remote := filepath.Join(root, "remote.git")
+	f.git(t, root, "init", "--bare", "-b", "main", remote)
+	f.git(t, root, "clone", remote, f.work)
+	f.commit(t, "app.go")
+	f.git(t, f.work, "push", "origin", "HEAD:main")
+	f.git(t, root, "clone", remote, f.checkout)
+
+	os.Setenv("REPODIR", f.checkout)
+	return f
+}
+
+// cleanup removes the repositories.
+func (f *fixture) cleanup() {
+	os.Unsetenv("REPODIR")
+	os.RemoveAll(f.root)
+}
+
+// git runs a git command in dir and returns its trimmed output.
+func (f *fixture) git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v failed: %v: %s", args, err, output)
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// commit changes a file in the working clone and commits it, returning the SHA.
+func (f *fixture) commit(t *testing.T, name string) string {
+	t.Helper()
+	f.commits++
+	path := filepath.Join(f.work, name)
+	os.MkdirAll(filepath.Dir(path), 0755)
+	if err := ioutil.WriteFile(path, []byte(fmt.Sprintf("revision %d\n", f.commits)), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+	f.git(t, f.work, "add", "-A")
+	f.git(t, f.work, "commit", "-m", "Change "+name)
+	return f.git(t, f.work, "rev-parse", "HEAD")
+}
+
+// TestRealGitAPI follows new commits on a local bare repository.
+func TestRealGitAPI(t *testing.T) {
+	f := newFixture(t)
+	defer f.cleanup()
+
+	ctx := context.Background()
+	git := &RealGitAPI{}
+
+	oldSha, err := git.GetCurrentSum("main")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Push two commits to the remote
+	first := f.commit(t, "docs/guide.md")
+	second := f.commit(t, "cmd/main.go")
+	f.git(t, f.work, "push", "origin", "HEAD:main")
+
+	sha, err := git.GetBranchSum(ctx, "main")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if sha != second {
+		t.Fatalf("Expected SHA '%s', got '%s'", second, sha)
+	}
+	if _, err := git.GetBranchSum(ctx, "missing"); err == nil {
+		t.Fatalf("Expected an error for a missing branch, but got nil")
+	}
+
+	diffs, err := git.CheckDifferences(ctx, oldSha, sha)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(diffs) != 2 || diffs[0] != "cmd/main.go" || diffs[1] != "docs/guide.md" {
+		t.Fatalf("Expected [cmd/main.go docs/guide.md], got %v", diffs)
+	}
+
+	commits, err := git.ListCommits(ctx, oldSha, sha)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(commits) != 2 || commits[0] != first || commits[1] != second {
+		t.Fatalf("Expected [%s %s], got %v", first, second, commits)
+	}
+}
+
+// TestLatestRelease picks the highest version among lightweight and annotated tags.
+func TestLatestRelease(t *testing.T) {
+	f := newFixture(t)
+	defer f.cleanup()
+
+	f.git(t, f.work, "tag", "v1.0.0")
+	want := f.commit(t, "app.go")
+	f.git(t, f.work, "tag", "-a", "v1.1.0", "-m", "Release 1.1.0")
+	f.commit(t, "app.go")
+	f.git(t, f.work, "tag", "v2.0.0-rc.1")
+	f.git(t, f.work, "push", "origin", "HEAD:main", "--tags")
+
+	git := &RealGitAPI{}
+	release, err := source.LatestRelease(context.Background(), git, "^1", false)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if release.Tag != "v1.1.0" || release.Sha != want {
+		t.Fatalf("Expected v1.1.0 (%s), got %s (%s)", want, release.Tag, release.Sha)
+	}
+}
+
+// TestCheckLastRun checks each CI gate.
+func TestCheckLastRun(t *testing.T) {
+	markerDir, err := ioutil.TempDir("", "markers")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(markerDir)
+	ioutil.WriteFile(filepath.Join(markerDir, "green-sha"), nil, 0644)
+	defer os.Unsetenv("GIT_CI_GATE")
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		sha  string
+		want source.CIState
+	}{
+		{name: "none", env: map[string]string{"GIT_CI_GATE": "none"}, sha: "any-sha", want: source.CIPassed},
+		{name: "marker present", env: map[string]string{"GIT_CI_GATE": "marker", "GIT_CI_MARKER": markerDir + "/{sha}"}, sha: "green-sha", want: source.CIPassed},
+		{name: "marker missing", env: map[string]string{"GIT_CI_GATE": "marker", "GIT_CI_MARKER": markerDir + "/{sha}"}, sha: "other-sha", want: source.CIPending},
+		{name: "command passes", env: map[string]string{"GIT_CI_GATE": "command", "GIT_CI_COMMAND": `test "$SHA" = green-sha`}, sha: "green-sha", want: source.CIPassed},
+		{name: "command fails", env: map[string]string{"GIT_CI_GATE": "command", "GIT_CI_COMMAND": `test "$SHA" = green-sha`}, sha: "red-sha", want: source.CIFailed},
+		{name: "command pending", env: map[string]string{"GIT_CI_GATE": "command", "GIT_CI_COMMAND": "exit 75"}, sha: "any-sha", want: source.CIPending},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				os.Setenv(key, value)
+			}
+			checks, _, err := (&RealGitAPI{}).CheckLastRun(context.Background(), tt.sha, nil)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if len(checks) != 1 || checks[0].State != tt.want {
+				t.Fatalf("Expected a single %s check, got %v", tt.want, checks)
+			}
+		})
+	}
+
+	os.Setenv("GIT_CI_GATE", "coinflip")
+	if _, _, err := (&RealGitAPI{}).CheckLastRun(context.Background(), "any-sha", nil); err == nil {
+		t.Fatalf("Expected an error for an unknown gate, but got nil")
+	}
+}
+
+// TestListCommits_FirstParent leaves out commits merged in from a side
+// branch, and fast-forwarding refuses a commit that doesn't descend from the
+// checkout.
+func TestListCommits_FirstParent(t *testing.T) {
+	f := newFixture(t)
+	defer f.cleanup()
+
+	ctx := context.Background()
+	git := &RealGitAPI{}
+
+	oldSha, err := git.GetCurrentSum("main")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// A side branch merged into main after another commit on main
+	f.git(t, f.work, "checkout", "-b", "side")
+	side := f.commit(t, "side.go")
+	f.git(t, f.work, "checkout", "main")
+	first := f.commit(t, "main.go")
+	f.git(t, f.work, "merge", "--no-ff", "-m", "Merge side", "side")
+	merge := f.git(t, f.work, "rev-parse", "HEAD")
+	f.git(t, f.work, "push", "origin", "HEAD:main")
+
+	commits, err := git.ListCommits(ctx, oldSha, merge)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(commits) != 2 || commits[0] != first || commits[1] != merge {
+		t.Fatalf("Expected [%s %s], got %v", first, merge, commits)
+	}
+
+	if err := git.FastForward(ctx, f.checkout, "main", first); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := git.FastForward(ctx, f.checkout, "main", side); err == nil {
+		t.Fatalf("Expected an error fast-forwarding to a commit off the branch, but got nil")
+	}
+}
@@ -0,0 +1,117 @@
+package source
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CIState is the combined outcome of the CI checks reported for a commit.
+type CIState string
+
+const (
+	CIPassed  CIState = "passed"
+	CIFailed  CIState = "failed"
+	CIPending CIState = "pending"
+)
+
+// CheckResult is the outcome of a single check run or commit status context.
+type CheckResult struct {
+	Name  string
+	State CIState
+}
+
+// Verdict is the result of evaluating a commit's checks against a CIPolicy.
+type Verdict struct {
+	State     CIState
+	Reason    string
+	Checks    []CheckResult
+	Workflows []CheckResult
+}
+
+// Passed reports whether the commit may be deployed.
+func (v Verdict) Passed() bool {
+	return v.State == CIPassed
+}
+
+// CIPolicy decides which checks have to pass before a commit is deployed.
+type CIPolicy struct {
+	// Mode is "all" (every reported check must pass, the default) or
+	// "required" (only the Required checks must pass).
+	Mode string
+	// Required names checks that must be reported and pass.
+	Required []string
+	// Optional names checks whose result is ignored.
+	Optional []string
+	// Workflows names GitHub Actions workflows, by name or file path, that
+	// must have concluded success for the commit.
+	Workflows []string
+	// NoChecks is "pending" (wait for checks to be reported, the default) or
+	// "pass" (deploy commits without any checks, for repositories without CI).
+	NoChecks string
+}
+
+// Evaluate combines check and required workflow results into a verdict. A
+// failed check wins over a pending one, and a required check that has not
+// reported yet counts as pending.
+func (p CIPolicy) Evaluate(checks, workflows []CheckResult) Verdict {
+	v := Verdict{State: CIPassed, Checks: checks, Workflows: workflows}
+	var failed, pending []string
+
+	for _, workflow := range workflows {
+		switch workflow.State {
+		case CIFailed:
+			failed = append(failed, "workflow "+workflow.Name)
+		case CIPending:
+			pending = append(pending, "workflow "+workflow.Name)
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, check := range checks {
+		seen[check.Name] = true
+		if containsName(p.Optional, check.Name) {
+			continue
+		}
+		if p.Mode == "required" && !containsName(p.Required, check.Name) {
+			continue
+		}
+		switch check.State {
+		case CIFailed:
+			failed = append(failed, check.Name)
+		case CIPending:
+			pending = append(pending, check.Name)
+		}
+	}
+	for _, name := range p.Required {
+		if !seen[name] {
+			pending = append(pending, name+" (not reported)")
+		}
+	}
+
+	switch {
+	case len(failed) > 0:
+		v.State = CIFailed
+		v.Reason = "failed: " + strings.Join(failed, ", ")
+	case len(pending) > 0:
+		v.State = CIPending
+		v.Reason = "pending: " + strings.Join(pending, ", ")
+	case len(checks) == 0 && len(workflows) == 0:
+		v.Reason = "no checks reported"
+		if p.NoChecks != "pass" {
+			v.State = CIPending
+		}
+	default:
+		v.Reason = fmt.Sprintf("%d checks and %d workflows passed", len(checks), len(workflows))
+	}
+	return v
+}
+
+// containsName reports whether name is in names.
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package source
+
+import "testing"
+
+// TestCIPolicyEvaluate checks how check results combine under each policy.
+func TestCIPolicyEvaluate(t *testing.T) {
+	pass := func(name string) CheckResult { return CheckResult{Name: name, State: CIPassed} }
+	fail := func(name string) CheckResult { return CheckResult{Name: name, State: CIFailed} }
+	pend := func(name string) CheckResult { return CheckResult{Name: name, State: CIPending} }
+
+	tests := []struct {
+		name   string
+		policy CIPolicy
+		checks []CheckResult
+		want   CIState
+	}{
+		{name: "all passed", checks: []CheckResult{pass("test"), pass("lint")}, want: CIPassed},
+		{name: "one failed", checks: []CheckResult{pass("test"), fail("lint")}, want: CIFailed},
+		{name: "failure wins over pending", checks: []CheckResult{pend("test"), fail("lint")}, want: CIFailed},
+		{name: "one pending", checks: []CheckResult{pass("test"), pend("lint")}, want: CIPending},
+		{name: "nothing reported", want: CIPending},
+		{name: "nothing reported passes", policy: CIPolicy{NoChecks: "pass"}, want: CIPassed},
+		{
+			name:   "required missing without checks",
+			policy: CIPolicy{Required: []string{"test"}, NoChecks: "pass"},
+			want:   CIPending,
+		},
+		{
+			name:   "optional failure ignored",
+			policy: CIPolicy{Optional: []string{"codecov"}},
+			checks: []CheckResult{pass("test"), fail("codecov")},
+			want:   CIPassed,
+		},
+		{
+			name:   "required missing",
+			policy: CIPolicy{Required: []string{"deploy-check"}},
+			checks: []CheckResult{pass("test")},
+			want:   CIPending,
+		},
+		{
+			name:   "required mode ignores others",
+			policy: CIPolicy{Mode: "required", Required: []string{"test"}},
+			checks: []CheckResult{pass("test"), fail("lint")},
+			want:   CIPassed,
+		},
+		{
+			name:   "required mode failure",
+			policy: CIPolicy{Mode: "required", Required: []string{"test"}},
+			checks: []CheckResult{fail("test"), pass("lint")},
+			want:   CIFailed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verdict := tt.policy.Evaluate(tt.checks, nil)
+			if verdict.State != tt.want {
+				t.Fatalf("Expected %s, got %s (%s)", tt.want, verdict.State, verdict.Reason)
+			}
+		})
+	}
+}
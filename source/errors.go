@@ -0,0 +1,106 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// StatusError reports an API response with an unexpected status code.
+type StatusError struct {
+	Code int
+	URL  string
+}
+
+// Error describes the status code and URL.
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status code %d from %s", e.Code, e.URL)
+}
+
+// RateLimitError reports that the hosting API's rate limit is exhausted and
+// requests are paused until the given time.
+type RateLimitError struct {
+	Until time.Time
+}
+
+// Error describes when requests resume.
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limit exceeded, requests paused until %s", e.Until.Format(time.RFC3339))
+}
+
+// FatalError marks an error that retrying can't fix, such as missing
+// configuration or rejected credentials, so the daemon should stop.
+type FatalError struct {
+	Err error
+}
+
+// Fatal marks err as fatal.
+func Fatal(err error) error {
+	return &FatalError{Err: err}
+}
+
+// Error returns the message of the underlying error.
+func (e *FatalError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *FatalError) Unwrap() error {
+	return e.Err
+}
+
+// IsFatal reports whether err was marked fatal or the API rejected the
+// credentials.
+func IsFatal(err error) bool {
+	var fatal *FatalError
+	var status *StatusError
+	return errors.As(err, &fatal) || (errors.As(err, &status) && status.Code == http.StatusUnauthorized)
+}
+
+// AccessError explains a failed check of the credentials against repo: a
+// rejected token, or a repository the token can't see. Other errors are
+// returned unchanged.
+func AccessError(repo string, err error) error {
+	var status *StatusError
+	if !errors.As(err, &status) {
+		return err
+	}
+	switch status.Code {
+	case http.StatusUnauthorized:
+		return fmt.Errorf("the token was rejected: %w", err)
+	case http.StatusForbidden, http.StatusNotFound:
+		return fmt.Errorf("repository %s not found or not visible to the token: %w", repo, err)
+	}
+	return err
+}
+
+// IsTransient reports whether err is likely to go away by itself: network
+// failures and timeouts, server errors, rate limits and truncated responses.
+func IsTransient(err error) bool {
+	if err == nil || IsFatal(err) || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var rateLimited *RateLimitError
+	var status *StatusError
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &rateLimited):
+		return true
+	case errors.As(err, &status):
+		return status.Code >= http.StatusInternalServerError || status.Code == http.StatusTooManyRequests
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.EOF):
+		return true
+	case errors.As(err, &opErr), errors.As(err, &dnsErr):
+		return true
+	case errors.As(err, &netErr):
+		return netErr.Timeout()
+	}
+	return false
+}
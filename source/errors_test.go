@@ -0,0 +1,121 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// TestClassifyErrors tests which errors are transient and which are fatal.
+func TestClassifyErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		transient bool
+		fatal     bool
+	}{
+		{name: "server error", err: &StatusError{Code: http.StatusBadGateway}, transient: true},
+		{name: "too many requests", err: &StatusError{Code: http.StatusTooManyRequests}, transient: true},
+		{name: "not found", err: &StatusError{Code: http.StatusNotFound}},
+		{name: "unauthorized", err: &StatusError{Code: http.StatusUnauthorized}, fatal: true},
+		{name: "rate limited", err: &RateLimitError{Until: time.Now()}, transient: true},
+		{name: "connection refused", err: &url.Error{Op: "Get", URL: "https://example.com", Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}}, transient: true},
+		{name: "truncated response", err: io.ErrUnexpectedEOF, transient: true},
+		{name: "deadline", err: fmt.Errorf("check: %w", context.DeadlineExceeded), transient: true},
+		{name: "canceled", err: context.Canceled},
+		{name: "marked fatal", err: fmt.Errorf("startup: %w", Fatal(errors.New("REPONAME environment variable not set"))), fatal: true},
+		{name: "other", err: errors.New("merge conflict")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsTransient(tt.err); got != tt.transient {
+				t.Errorf("Expected IsTransient to be %v, got %v", tt.transient, got)
+			}
+			if got := IsFatal(tt.err); got != tt.fatal {
+				t.Errorf("Expected IsFatal to be %v, got %v", tt.fatal, got)
+			}
+		})
+	}
+}
+
+// TestAccessError tests that rejected and hidden repositories are explained,
+// and that other errors are left alone.
+func TestAccessError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{err: &StatusError{Code: http.StatusUnauthorized, URL: "u"}, want: "the token was rejected: unexpected status code 401 from u"},
+		{err: &StatusError{Code: http.StatusNotFound, URL: "u"}, want: "repository owner/app not found or not visible to the token: unexpected status code 404 from u"},
+		{err: &StatusError{Code: http.StatusBadGateway, URL: "u"}, want: "unexpected status code 502 from u"},
+		{err: errors.New("connection refused"), want: "connection refused"},
+	}
+	for _, tt := range tests {
+		if got := AccessError("owner/app", tt.err).Error(); got != tt.want {
+			t.Errorf("Expected '%s', got '%s'", tt.want, got)
+		}
+	}
+}
+
+// TestGetJSON_Retry tests that server errors are retried until the request
+// succeeds, and that client errors aren't retried.
+func TestGetJSON_Retry(t *testing.T) {
+	retryDelay := RetryDelay
+	RetryDelay = time.Millisecond
+	defer func() { RetryDelay = retryDelay }()
+
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch {
+		case r.URL.Path == "/missing":
+			http.NotFound(w, r)
+		case requests < 3:
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		default:
+			w.Write([]byte(`{"sha": "fake-sha"}`))
+		}
+	}))
+	defer ts.Close()
+
+	var result struct {
+		Sha string `json:"sha"`
+	}
+	if _, err := GetJSON(context.Background(), ts.URL, nil, &result); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result.Sha != "fake-sha" || requests != 3 {
+		t.Fatalf("Expected 'fake-sha' after 3 requests, got '%s' after %d", result.Sha, requests)
+	}
+
+	requests = 0
+	if _, err := GetJSON(context.Background(), ts.URL+"/missing", nil, &result); err == nil {
+		t.Fatalf("Expected an error, but got nil")
+	}
+	if requests != 1 {
+		t.Fatalf("Expected a 404 not to be retried, but got %d requests", requests)
+	}
+}
+
+// TestRetry_GivesUp tests that a transient failure is tried retryAttempts times.
+func TestRetry_GivesUp(t *testing.T) {
+	retryDelay := RetryDelay
+	RetryDelay = time.Millisecond
+	defer func() { RetryDelay = retryDelay }()
+
+	calls := 0
+	err := Retry(context.Background(), "test", func() error {
+		calls++
+		return &StatusError{Code: http.StatusInternalServerError}
+	})
+	if err == nil || calls != retryAttempts {
+		t.Fatalf("Expected an error after %d calls, got %v after %d", retryAttempts, err, calls)
+	}
+}
@@ -0,0 +1,174 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"autopuller/env"
+)
+
+// Define function variables that can be overridden in tests
+var execCommandContext = exec.CommandContext
+
+// gitCommand prepares a command to run inside repoDir. git is told the
+// checkout is safe to use even if another user owns it, for this command
+// only, so concurrent projects never write the global config.
+func gitCommand(ctx context.Context, repoDir string, args ...string) *exec.Cmd {
+	if args[0] == "git" {
+		args = append([]string{"git", "-c", "safe.directory=" + repoDir}, args[1:]...)
+	}
+	cmd := execCommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = repoDir
+	return cmd
+}
+
+// GitCheckout implements the Source functions that act on the local checkout
+// with git. Backends embed it alongside their hosting API calls, and read the
+// repository they follow from it.
+type GitCheckout struct {
+	Repo string // Repository on the hosting service, the configured repo if empty
+	Dir  string // Local checkout, the configured repodir if empty
+}
+
+// RepoName returns the repository followed, e.g. "owner/name".
+func (g GitCheckout) RepoName() string {
+	if g.Repo != "" {
+		return g.Repo
+	}
+	return env.Current().Repo
+}
+
+// RepoDir returns the absolute path of the checkout. Paths are resolved
+// against the working directory at startup, which never changes.
+func (g GitCheckout) RepoDir() string {
+	dir := g.Dir
+	if dir == "" {
+		dir = env.Current().RepoDir
+	}
+	if abs, err := filepath.Abs(dir); err == nil {
+		return abs
+	}
+	return dir
+}
+
+// GetCurrentSum resolves the commit SHA checked out locally at HEAD.
+// It fails if HEAD is on a different branch than the one being tracked;
+// an empty branch (release mode) accepts any checkout.
+func (g GitCheckout) GetCurrentSum(branch string) (string, error) {
+	sha, headBranch, err := ResolveHead(g.RepoDir())
+	if err != nil {
+		return "", err
+	}
+	if branch != "" && headBranch != "" && headBranch != branch {
+		return "", fmt.Errorf("checkout is on branch %s, expected %s", headBranch, branch)
+	}
+	return sha, nil
+}
+
+// CheckCheckout confirms that repoDir is a git checkout without uncommitted
+// changes, on the given branch unless it is empty (release mode) or HEAD is
+// detached, and describes where HEAD is.
+func CheckCheckout(ctx context.Context, repoDir, branch string) (string, error) {
+	sha, headBranch, err := ResolveHead(repoDir)
+	if err != nil {
+		return "", fmt.Errorf("%s is not a git checkout: %v", repoDir, err)
+	}
+	if branch != "" && headBranch != "" && headBranch != branch {
+		return "", fmt.Errorf("checkout is on branch %s, expected %s", headBranch, branch)
+	}
+
+	output, err := gitCommand(ctx, repoDir, "git", "status", "--porcelain").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to run git status: %v", err)
+	}
+	if changes := strings.Split(strings.TrimSpace(string(output)), "\n"); changes[0] != "" {
+		return "", fmt.Errorf("checkout has %d uncommitted change(s), e.g. %s", len(changes), strings.TrimSpace(changes[0]))
+	}
+
+	if headBranch == "" {
+		return "detached HEAD at " + sha, nil
+	}
+	return headBranch + " at " + sha, nil
+}
+
+// RunGitPull runs git-related commands to update the repository from the given branch.
+func (g GitCheckout) RunGitPull(ctx context.Context, repoDir, branch string) error {
+	// Set git credential helper
+	commands := [][]string{
+		{"git", "config", "credential.helper", "store"},
+		{"git", "pull", "origin", branch},
+	}
+	return runGitCommands(ctx, repoDir, commands)
+}
+
+// FastForward fetches the given branch and fast-forwards the checkout to a
+// specific commit on it rather than its tip. The commit must descend from the
+// checked out one.
+func (g GitCheckout) FastForward(ctx context.Context, repoDir, branch, sha string) error {
+	commands := [][]string{
+		{"git", "config", "credential.helper", "store"},
+		{"git", "fetch", "origin", branch},
+	}
+	if err := runGitCommands(ctx, repoDir, commands); err != nil {
+		return err
+	}
+
+	if err := gitCommand(ctx, repoDir, "git", "merge-base", "--is-ancestor", "HEAD", sha).Run(); err != nil {
+		return fmt.Errorf("commit %s does not descend from the checked out commit: %v", sha, err)
+	}
+	return runGitCommands(ctx, repoDir, [][]string{{"git", "merge", "--ff-only", sha}})
+}
+
+// CheckoutTag fetches tags and checks out the given tag as a detached HEAD.
+func (g GitCheckout) CheckoutTag(ctx context.Context, repoDir, tag string) error {
+	commands := [][]string{
+		{"git", "config", "credential.helper", "store"},
+		{"git", "fetch", "--tags", "origin"},
+		{"git", "checkout", "--detach", "refs/tags/" + tag},
+	}
+	return runGitCommands(ctx, repoDir, commands)
+}
+
+// LocalDifferences fetches from origin and lists the files changed between
+// two SHAs with git diff. Backends use it when their API can't list every
+// changed file.
+func LocalDifferences(ctx context.Context, repoDir, oldSha, newSha string) ([]string, error) {
+	commands := [][]string{
+		{"git", "fetch", "--tags", "origin"},
+	}
+	if err := runGitCommands(ctx, repoDir, commands); err != nil {
+		return nil, err
+	}
+
+	output, err := gitCommand(ctx, repoDir, "git", "diff", "--name-only", oldSha, newSha).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run git diff: %v", err)
+	}
+	var diffs []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			diffs = append(diffs, line)
+		}
+	}
+	return diffs, nil
+}
+
+// runGitCommands runs each command in order inside repoDir, stopping at the first failure.
+func runGitCommands(ctx context.Context, repoDir string, commands [][]string) error {
+	logger := Logger(ctx)
+	for _, cmdArgs := range commands {
+		cmd := gitCommand(ctx, repoDir, cmdArgs...)
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			logger.Printf("Error running command %s: %v", cmdArgs[0], err)
+			logger.Printf("Output: %s", output)
+			return fmt.Errorf("failed to run git command: %s", cmdArgs)
+		}
+		logger.Printf("Command %s successful: %s", cmdArgs[0], string(output))
+	}
+
+	return nil
+}
@@ -0,0 +1,304 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// mockExecCommand is used to replace exec.CommandContext in the test to avoid executing real commands.
+var mockExecCommand = func(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cs := []string{"-test.run=TestHelperProcess", "--", name}
+	cs = append(cs, args...)
+	cmd := exec.Command(os.Args[0], cs...)
+	cmd.Env = []string{"GO_WANT_HELPER_PROCESS=1"}
+	return cmd
+}
+
+// TestHelperProcess is used as a helper to mock exec.CommandContext during tests.
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+
+	// Simulate successful command execution
+	os.Exit(0)
+}
+
+// TestRunGitPull_Success simulates the success scenario of the RunGitPull function.
+func TestRunGitPull_Success(t *testing.T) {
+	// Save original execCommandContext
+	originalExecCommandContext := execCommandContext
+
+	// Restore it after the test
+	defer func() {
+		execCommandContext = originalExecCommandContext
+	}()
+
+	// Mock execCommandContext to use mockExecCommand instead of running real commands
+	execCommandContext = mockExecCommand
+
+	// Create a GitCheckout and call RunGitPull
+	checkout := GitCheckout{}
+	err := checkout.RunGitPull(context.Background(), os.TempDir(), "master")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+}
+
+// TestRunGitPull_Failure simulates a failure scenario where a git command fails.
+func TestRunGitPull_Failure(t *testing.T) {
+	// Save original execCommandContext
+	originalExecCommandContext := execCommandContext
+
+	// Restore it after the test
+	defer func() {
+		execCommandContext = originalExecCommandContext
+	}()
+
+	// Mock execCommandContext to simulate a failure for one of the git commands
+	execCommandContext = func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		cs := []string{"-test.run=TestHelperProcessFail", "--", name}
+		cs = append(cs, args...)
+		cmd := exec.Command(os.Args[0], cs...)
+		cmd.Env = []string{"GO_WANT_HELPER_PROCESS_FAIL=1"}
+		return cmd
+	}
+
+	// Create a GitCheckout and call RunGitPull
+	checkout := GitCheckout{}
+	err := checkout.RunGitPull(context.Background(), os.TempDir(), "master")
+
+	// We expect an error here because of the simulated failure
+	if err == nil {
+		t.Fatalf("Expected error, but got nil")
+	}
+}
+
+// TestFastForward_Success simulates fast-forwarding to a specific commit.
+func TestFastForward_Success(t *testing.T) {
+	originalExecCommandContext := execCommandContext
+	defer func() {
+		execCommandContext = originalExecCommandContext
+	}()
+
+	execCommandContext = mockExecCommand
+
+	checkout := GitCheckout{}
+	if err := checkout.FastForward(context.Background(), os.TempDir(), "master", "fake-sha"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+}
+
+// TestCheckoutTag_Success checks that the tag checkout commands run.
+func TestCheckoutTag_Success(t *testing.T) {
+	originalExecCommandContext := execCommandContext
+	defer func() {
+		execCommandContext = originalExecCommandContext
+	}()
+
+	execCommandContext = mockExecCommand
+
+	if err := (GitCheckout{}).CheckoutTag(context.Background(), os.TempDir(), "v1.4.2"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+}
+
+// TestHelperProcessFail simulates a command failure scenario.
+func TestHelperProcessFail(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS_FAIL") != "1" {
+		return
+	}
+
+	// Simulate a failure by returning non-zero exit code
+	os.Exit(1)
+}
+
+// mockExecDiff is used to replace exec.CommandContext with a git that prints a changed file list.
+var mockExecDiff = func(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cs := []string{"-test.run=TestHelperProcessDiff", "--", name}
+	cs = append(cs, args...)
+	cmd := exec.Command(os.Args[0], cs...)
+	cmd.Env = []string{"GO_WANT_HELPER_PROCESS_DIFF=1"}
+	return cmd
+}
+
+// TestHelperProcessDiff simulates git diff --name-only output.
+func TestHelperProcessDiff(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS_DIFF") != "1" {
+		return
+	}
+
+	fmt.Println("local1.txt")
+	fmt.Println("local2.txt")
+	os.Exit(0)
+}
+
+// Test GetCurrentSum by mocking file system operations.
+func TestGetCurrentSum_Success(t *testing.T) {
+	// Manually create a temporary directory
+	repoDir, err := ioutil.TempDir("", "repo")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(repoDir) // Clean up after the test
+
+	// Mock REPODIR environment variable
+	os.Setenv("REPODIR", repoDir)
+
+	// Create a fake HEAD pointing at a master file with a commit SHA
+	masterFile := filepath.Join(repoDir, ".git/refs/heads/master")
+	os.MkdirAll(filepath.Dir(masterFile), 0755)
+	ioutil.WriteFile(masterFile, []byte(shaA), 0644)
+	ioutil.WriteFile(filepath.Join(repoDir, ".git/HEAD"), []byte("ref: refs/heads/master\n"), 0644)
+
+	// Call GetCurrentSum and check the result
+	sha, err := GitCheckout{}.GetCurrentSum("master")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if sha != shaA {
+		t.Fatalf("Expected SHA '%s', got '%s'", shaA, sha)
+	}
+}
+
+// TestGetCurrentSum_WrongBranch checks that a checkout on another branch is rejected.
+func TestGetCurrentSum_WrongBranch(t *testing.T) {
+	repoDir, err := ioutil.TempDir("", "repo")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(repoDir)
+
+	os.Setenv("REPODIR", repoDir)
+
+	// HEAD points at a feature branch instead of master
+	writeFixture(t, repoDir, map[string]string{
+		".git/HEAD":               "ref: refs/heads/feature\n",
+		".git/refs/heads/feature": shaB + "\n",
+	})
+
+	if _, err := (GitCheckout{}).GetCurrentSum("master"); err == nil {
+		t.Fatalf("Expected an error for a checkout on the wrong branch, but got nil")
+	}
+}
+
+// TestGitCheckout_Project checks that a checkout's own repository and
+// directory take precedence over REPONAME and REPODIR.
+func TestGitCheckout_Project(t *testing.T) {
+	repoDir, err := ioutil.TempDir("", "repo")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(repoDir)
+
+	os.Setenv("REPONAME", "env/repo")
+	os.Setenv("REPODIR", os.TempDir())
+	defer os.Unsetenv("REPONAME")
+
+	writeFixture(t, repoDir, map[string]string{
+		".git/HEAD":              "ref: refs/heads/master\n",
+		".git/refs/heads/master": shaB + "\n",
+	})
+
+	checkout := GitCheckout{Repo: "project/repo", Dir: repoDir}
+	if name := checkout.RepoName(); name != "project/repo" {
+		t.Fatalf("Expected repository 'project/repo', got '%s'", name)
+	}
+	sha, err := checkout.GetCurrentSum("master")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if sha != shaB {
+		t.Fatalf("Expected SHA '%s', got '%s'", shaB, sha)
+	}
+}
+
+// TestLocalDifferences simulates listing changed files with git diff.
+func TestLocalDifferences(t *testing.T) {
+	originalExecCommandContext := execCommandContext
+	defer func() {
+		execCommandContext = originalExecCommandContext
+	}()
+
+	execCommandContext = mockExecDiff
+
+	diffs, err := LocalDifferences(context.Background(), os.TempDir(), "oldSha", "newSha")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(diffs) != 2 || diffs[0] != "local1.txt" || diffs[1] != "local2.txt" {
+		t.Fatalf("Expected [local1.txt local2.txt], got %v", diffs)
+	}
+}
+
+// TestRunGitPull_KeepsWorkingDirectory checks that git runs inside the
+// checkout without changing the process's working directory.
+func TestRunGitPull_KeepsWorkingDirectory(t *testing.T) {
+	originalExecCommandContext := execCommandContext
+	defer func() {
+		execCommandContext = originalExecCommandContext
+	}()
+	execCommandContext = mockExecCommand
+
+	before, _ := os.Getwd()
+	if err := (GitCheckout{}).RunGitPull(context.Background(), os.TempDir(), "master"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if after, _ := os.Getwd(); after != before {
+		t.Fatalf("Expected the working directory to stay %s, got %s", before, after)
+	}
+
+	cmd := gitCommand(context.Background(), os.TempDir(), "git", "status")
+	if cmd.Dir != os.TempDir() {
+		t.Fatalf("Expected git to run in %s, got '%s'", os.TempDir(), cmd.Dir)
+	}
+
+	// The checkout is trusted per command, never in the global git config
+	want := "git -c safe.directory=" + os.TempDir() + " status"
+	if args := strings.Join(cmd.Args, " "); !strings.HasSuffix(args, want) {
+		t.Fatalf("Expected the command to end with '%s', got '%s'", want, args)
+	}
+}
+
+// TestCheckCheckout checks that the checkout must be on the tracked branch
+// with a clean tree.
+func TestCheckCheckout(t *testing.T) {
+	originalExecCommandContext := execCommandContext
+	defer func() {
+		execCommandContext = originalExecCommandContext
+	}()
+
+	repoDir, err := ioutil.TempDir("", "repo")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(repoDir)
+	writeFixture(t, repoDir, map[string]string{
+		".git/HEAD":              "ref: refs/heads/master\n",
+		".git/refs/heads/master": shaA + "\n",
+	})
+
+	// git status prints nothing for a clean tree
+	execCommandContext = mockExecCommand
+	if desc, err := CheckCheckout(context.Background(), repoDir, "master"); err != nil || desc != "master at "+shaA {
+		t.Fatalf("Expected 'master at %s', got '%s' (%v)", shaA, desc, err)
+	}
+	if _, err := CheckCheckout(context.Background(), repoDir, "main"); err == nil {
+		t.Fatalf("Expected an error for a checkout on the wrong branch, but got nil")
+	}
+	if _, err := CheckCheckout(context.Background(), os.TempDir(), "master"); err == nil {
+		t.Fatalf("Expected an error for a directory that isn't a checkout, but got nil")
+	}
+
+	// Any output of git status is an uncommitted change
+	execCommandContext = mockExecDiff
+	if _, err := CheckCheckout(context.Background(), repoDir, "master"); err == nil {
+		t.Fatalf("Expected an error for uncommitted changes, but got nil")
+	}
+}
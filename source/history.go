@@ -0,0 +1,28 @@
+package source
+
+// FirstParentLine returns the commits on the first-parent line from newSha
+// back to the first commit not in parents, such as the deployed commit, in
+// oldest first order. parents maps each commit listed by a compare API to
+// its parents. Commits merged in from side branches are left out: they were
+// never the tip of the branch and need not descend from the deployed commit,
+// so fast-forwarding to one could fail or skip back in history.
+func FirstParentLine(newSha string, parents map[string][]string) []string {
+	var line []string
+	for sha := newSha; len(line) < len(parents); {
+		shaParents, ok := parents[sha]
+		if !ok {
+			break
+		}
+		line = append(line, sha)
+		if len(shaParents) == 0 {
+			break
+		}
+		sha = shaParents[0]
+	}
+
+	// Oldest first
+	for i, j := 0, len(line)-1; i < j; i, j = i+1, j-1 {
+		line[i], line[j] = line[j], line[i]
+	}
+	return line
+}
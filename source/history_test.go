@@ -0,0 +1,28 @@
+package source
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestFirstParentLine checks that commits merged in from a side branch are
+// left out and the line stops at the deployed commit.
+func TestFirstParentLine(t *testing.T) {
+	// old <- a <- merge(a, side) <- tip, with side <- side2 branched off old
+	parents := map[string][]string{
+		"a":     {"old"},
+		"side":  {"old"},
+		"side2": {"side"},
+		"merge": {"a", "side2"},
+		"tip":   {"merge"},
+	}
+
+	line := FirstParentLine("tip", parents)
+	if expected := []string{"a", "merge", "tip"}; !reflect.DeepEqual(line, expected) {
+		t.Fatalf("Expected %v, got %v", expected, line)
+	}
+
+	if line := FirstParentLine("unknown", parents); len(line) != 0 {
+		t.Fatalf("Expected no commits for an unlisted tip, got %v", line)
+	}
+}
@@ -0,0 +1,112 @@
+package source
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// HTTPClient is the client used for all hosting API requests.
+var HTTPClient = http.DefaultClient
+
+// UseCABundle makes HTTPClient trust the PEM certificates in the file in
+// addition to the system roots, for instances signed by an internal CA.
+// An empty path leaves the client unchanged.
+func UseCABundle(path string) error {
+	if path == "" {
+		return nil
+	}
+	pem, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read CA bundle: %v", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return fmt.Errorf("no certificates found in CA bundle %s", path)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	HTTPClient = &http.Client{Transport: transport}
+	return nil
+}
+
+// Transport returns the transport of HTTPClient, for clients that wrap it.
+func Transport() http.RoundTripper {
+	if HTTPClient.Transport != nil {
+		return HTTPClient.Transport
+	}
+	return http.DefaultTransport
+}
+
+// GetJSON performs a GET request with the given headers and decodes the JSON
+// response into result. It returns the URL of the next page from the Link
+// header, or an empty string when there are no more pages.
+func GetJSON(ctx context.Context, url string, header http.Header, result interface{}) (string, error) {
+	return GetJSONWith(ctx, HTTPClient, url, header, result)
+}
+
+// GetJSONWith is GetJSON using the given client, such as one that adds
+// credentials to each request. Transient failures are retried.
+func GetJSONWith(ctx context.Context, client *http.Client, url string, header http.Header, result interface{}) (string, error) {
+	var next string
+	err := Retry(ctx, "GET "+url, func() error {
+		var err error
+		next, err = getJSON(ctx, client, url, header, result)
+		return err
+	})
+	return next, err
+}
+
+// getJSON performs a single attempt of GetJSONWith.
+func getJSON(ctx context.Context, client *http.Client, url string, header http.Header, result interface{}) (string, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(ctx)
+	for key, values := range header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", &StatusError{Code: resp.StatusCode, URL: url}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return "", err
+	}
+	return nextPageURL(resp.Header.Get("Link")), nil
+}
+
+// nextPageURL extracts the rel="next" URL from a Link header.
+func nextPageURL(link string) string {
+	for _, part := range strings.Split(link, ",") {
+		segments := strings.Split(part, ";")
+		if len(segments) < 2 {
+			continue
+		}
+		for _, param := range segments[1:] {
+			if strings.TrimSpace(param) == `rel="next"` {
+				return strings.Trim(strings.TrimSpace(segments[0]), "<>")
+			}
+		}
+	}
+	return ""
+}
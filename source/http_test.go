@@ -0,0 +1,50 @@
+package source
+
+import (
+	"context"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+// TestUseCABundle checks that a server signed by an unknown CA is only
+// trusted once its certificate is in the CA bundle.
+func TestUseCABundle(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"sha": "fake-sha"}`))
+	}))
+	defer ts.Close()
+	defer func() { HTTPClient = http.DefaultClient }()
+
+	var result struct {
+		Sha string `json:"sha"`
+	}
+	if _, err := GetJSON(context.Background(), ts.URL, nil, &result); err == nil {
+		t.Fatalf("Expected a certificate error, but got nil")
+	}
+
+	bundle, err := ioutil.TempFile("", "ca-*.pem")
+	if err != nil {
+		t.Fatalf("Failed to create CA bundle: %v", err)
+	}
+	defer os.Remove(bundle.Name())
+	pem.Encode(bundle, &pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	bundle.Close()
+
+	if err := UseCABundle(bundle.Name()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := GetJSON(context.Background(), ts.URL, nil, &result); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result.Sha != "fake-sha" {
+		t.Fatalf("Expected SHA 'fake-sha', got '%s'", result.Sha)
+	}
+
+	if err := UseCABundle(bundle.Name() + ".missing"); err == nil {
+		t.Fatalf("Expected an error for a missing CA bundle, but got nil")
+	}
+}
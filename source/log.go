@@ -0,0 +1,24 @@
+package source
+
+import (
+	"context"
+	"log"
+)
+
+// loggerKey is the context key of the logger for a project's operations.
+type loggerKey struct{}
+
+// WithLogger records the logger that operations made with ctx log to, so
+// the output of each project's checks carries its prefix.
+func WithLogger(ctx context.Context, logger *log.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// Logger returns the logger recorded in ctx, or one writing to the standard
+// logger's output.
+func Logger(ctx context.Context) *log.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*log.Logger); ok && logger != nil {
+		return logger
+	}
+	return log.New(log.Writer(), log.Prefix(), log.Flags())
+}
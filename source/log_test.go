@@ -0,0 +1,23 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strings"
+	"testing"
+)
+
+// TestLogger checks that operations log with the logger of their context.
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := WithLogger(context.Background(), log.New(&buf, "[app] ", 0))
+
+	Logger(ctx).Printf("Checking")
+	if got := buf.String(); got != "[app] Checking\n" {
+		t.Fatalf("Expected '[app] Checking', got '%s'", strings.TrimSpace(got))
+	}
+	if Logger(context.Background()) == nil {
+		t.Fatalf("Expected a default logger, got nil")
+	}
+}
@@ -0,0 +1,125 @@
+package source
+
+import (
+	"context"
+	"errors"
+)
+
+// MockSource is a mock implementation of the Source interface for testing purposes.
+type MockSource struct {
+	// Fields to override the return values of the methods for specific test scenarios
+	OverrideBranchSum          string
+	OverrideCurrentSum         string
+	OverrideCheckLastRun       bool
+	ShouldFailBranchSum        bool
+	ShouldFailCurrentSum       bool
+	ShouldFailCheckRun         bool
+	ShouldFailUpdateSum        bool
+	ShouldFailCheckDifferences bool
+	FileDifferences            []string
+	ShouldFailRunGitPull       bool
+	OverrideRelease            Release
+	ShouldFailLatestRelease    bool
+	ShouldFailCheckoutTag      bool
+	OverridePending            bool
+	GreenShas                  []string
+	Commits                    []string
+	ShouldFailListCommits      bool
+	ShouldFailFastForward      bool
+
+	// LastBranch records the branch name passed to the most recent branch-aware call
+	LastBranch string
+	// CheckedOutTag records the tag passed to CheckoutTag
+	CheckedOutTag string
+	// CheckLastRunCalls counts the calls to CheckLastRun
+	CheckLastRunCalls int
+	// FastForwardedSha records the commit passed to FastForward
+	FastForwardedSha string
+}
+
+// GetBranchSum simulates fetching the latest commit SHA of a branch from the remote.
+func (m *MockSource) GetBranchSum(ctx context.Context, branch string) (string, error) {
+	m.LastBranch = branch
+	if m.ShouldFailBranchSum {
+		return "", errors.New("failed to get branch sum")
+	}
+	return m.OverrideBranchSum, nil
+}
+
+// GetCurrentSum simulates reading the current commit SHA from the local file system.
+func (m *MockSource) GetCurrentSum(branch string) (string, error) {
+	m.LastBranch = branch
+	if m.ShouldFailCurrentSum {
+		return "", errors.New("failed to get current sum")
+	}
+	return m.OverrideCurrentSum, nil
+}
+
+// ListReleases simulates listing the releases of the repository, which only
+// has OverrideRelease.
+func (m *MockSource) ListReleases(ctx context.Context) ([]ReleaseCandidate, error) {
+	if m.ShouldFailLatestRelease {
+		return nil, errors.New("failed to list releases")
+	}
+	return []ReleaseCandidate{{Tag: m.OverrideRelease.Tag, Sha: m.OverrideRelease.Sha}}, nil
+}
+
+// CheckLastRun simulates fetching the CI checks of a commit as a single check.
+func (m *MockSource) CheckLastRun(ctx context.Context, sha string, workflows []string) ([]CheckResult, []CheckResult, error) {
+	m.CheckLastRunCalls++
+	if m.ShouldFailCheckRun {
+		return nil, nil, errors.New("failed to check last run")
+	}
+	check := CheckResult{Name: "mock", State: CIFailed}
+	if m.OverrideCheckLastRun || containsName(m.GreenShas, sha) {
+		check.State = CIPassed
+	}
+	if m.OverridePending {
+		check.State = CIPending
+	}
+	return []CheckResult{check}, nil, nil
+}
+
+// CheckDifferences simulates checking for file differences between two SHAs.
+func (m *MockSource) CheckDifferences(ctx context.Context, oldSha, newSha string) ([]string, error) {
+	if m.ShouldFailCheckDifferences {
+		return nil, errors.New("failed to check differences")
+	}
+	return m.FileDifferences, nil
+}
+
+// ListCommits simulates listing the commits between two SHAs.
+func (m *MockSource) ListCommits(ctx context.Context, oldSha, newSha string) ([]string, error) {
+	if m.ShouldFailListCommits {
+		return nil, errors.New("failed to list commits")
+	}
+	return m.Commits, nil
+}
+
+// RunGitPull simulates running a git pull command.
+func (m *MockSource) RunGitPull(ctx context.Context, repoDir, branch string) error {
+	m.LastBranch = branch
+	if m.ShouldFailRunGitPull {
+		return errors.New("failed to run git pull")
+	}
+	return nil
+}
+
+// CheckoutTag simulates checking out a release tag.
+func (m *MockSource) CheckoutTag(ctx context.Context, repoDir, tag string) error {
+	if m.ShouldFailCheckoutTag {
+		return errors.New("failed to check out tag")
+	}
+	m.CheckedOutTag = tag
+	return nil
+}
+
+// FastForward simulates fast-forwarding to a specific commit.
+func (m *MockSource) FastForward(ctx context.Context, repoDir, branch, sha string) error {
+	m.LastBranch = branch
+	if m.ShouldFailFastForward {
+		return errors.New("failed to fast-forward")
+	}
+	m.FastForwardedSha = sha
+	return nil
+}
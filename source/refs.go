@@ -0,0 +1,189 @@
+package source
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxSymrefDepth mirrors git's limit on how many symbolic refs are followed.
+const maxSymrefDepth = 5
+
+// gitDirs holds the two directories git looks refs up in. For a normal
+// checkout both point at .git; for a linked worktree gitDir is the per-worktree
+// directory (holding HEAD) and commonDir is the main repository's .git.
+type gitDirs struct {
+	gitDir    string
+	commonDir string
+}
+
+// findGitDirs locates the git directories for a checkout, following a
+// `gitdir:` file (worktrees, submodules) and a `commondir` file if present.
+func findGitDirs(repoDir string) (gitDirs, error) {
+	dotGit := filepath.Join(repoDir, ".git")
+	info, err := os.Stat(dotGit)
+	if err != nil {
+		return gitDirs{}, err
+	}
+
+	gitDir := dotGit
+	if !info.IsDir() {
+		data, err := ioutil.ReadFile(dotGit)
+		if err != nil {
+			return gitDirs{}, err
+		}
+		line := strings.TrimSpace(string(data))
+		if !strings.HasPrefix(line, "gitdir:") {
+			return gitDirs{}, fmt.Errorf("invalid gitfile format: %s", dotGit)
+		}
+		gitDir = strings.TrimSpace(strings.TrimPrefix(line, "gitdir:"))
+		if !filepath.IsAbs(gitDir) {
+			gitDir = filepath.Join(repoDir, gitDir)
+		}
+	}
+
+	dirs := gitDirs{gitDir: gitDir, commonDir: gitDir}
+	if data, err := ioutil.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		commonDir := strings.TrimSpace(string(data))
+		if !filepath.IsAbs(commonDir) {
+			commonDir = filepath.Join(gitDir, commonDir)
+		}
+		dirs.commonDir = commonDir
+	}
+	return dirs, nil
+}
+
+// isPerWorktreeRef reports whether a ref lives in the per-worktree git directory
+// rather than the common one.
+func isPerWorktreeRef(name string) bool {
+	return !strings.Contains(name, "/") || strings.HasPrefix(name, "refs/worktree/") ||
+		strings.HasPrefix(name, "refs/bisect/") || strings.HasPrefix(name, "refs/rewritten/")
+}
+
+// readLooseRef reads a loose ref file, returning its raw trimmed content.
+func (d gitDirs) readLooseRef(name string) (string, bool, error) {
+	dir := d.commonDir
+	if isPerWorktreeRef(name) {
+		dir = d.gitDir
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+	if os.IsNotExist(err) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return strings.TrimSpace(string(data)), true, nil
+}
+
+// readPackedRef looks a ref up in the packed-refs file of the common directory.
+func (d gitDirs) readPackedRef(name string) (string, bool, error) {
+	file, err := os.Open(filepath.Join(d.commonDir, "packed-refs"))
+	if os.IsNotExist(err) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		// Skip the header and peeled tag lines
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "^") {
+			continue
+		}
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) == 2 && fields[1] == name {
+			return fields[0], true, nil
+		}
+	}
+	return "", false, scanner.Err()
+}
+
+// resolve follows a ref to a commit SHA. It returns the SHA and the name of
+// the last ref in the chain (e.g. refs/heads/main when resolving HEAD).
+func (d gitDirs) resolve(name string) (string, string, error) {
+	for depth := 0; depth <= maxSymrefDepth; depth++ {
+		content, found, err := d.readLooseRef(name)
+		if err != nil {
+			return "", "", err
+		}
+		if !found && !isPerWorktreeRef(name) {
+			content, found, err = d.readPackedRef(name)
+			if err != nil {
+				return "", "", err
+			}
+		}
+		if !found {
+			return "", "", fmt.Errorf("ref %s not found", name)
+		}
+
+		if strings.HasPrefix(content, "ref:") {
+			name = strings.TrimSpace(strings.TrimPrefix(content, "ref:"))
+			continue
+		}
+		if !isHexSha(content) {
+			return "", "", fmt.Errorf("ref %s has invalid content %q", name, content)
+		}
+		return content, name, nil
+	}
+	return "", "", fmt.Errorf("too many levels of symbolic refs resolving %s", name)
+}
+
+// isHexSha reports whether s looks like a full SHA-1 or SHA-256 object name.
+func isHexSha(s string) bool {
+	if len(s) != 40 && len(s) != 64 {
+		return false
+	}
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// GitDir gets the git directory of the checkout at repoDir, which is the
+// target of its `gitdir:` file in a worktree. If it can't be found, the .git
+// directory git would create is returned.
+func GitDir(repoDir string) string {
+	dirs, err := findGitDirs(repoDir)
+	if err != nil {
+		return filepath.Join(repoDir, ".git")
+	}
+	return dirs.gitDir
+}
+
+// ResolveRef resolves a ref (e.g. HEAD or refs/heads/main) in the checkout at
+// repoDir the same way git does: through symbolic refs, loose refs,
+// packed-refs and `gitdir:` indirections.
+func ResolveRef(repoDir, name string) (string, error) {
+	dirs, err := findGitDirs(repoDir)
+	if err != nil {
+		return "", err
+	}
+	sha, _, err := dirs.resolve(name)
+	return sha, err
+}
+
+// ResolveHead resolves HEAD in the checkout at repoDir. It returns the commit
+// SHA and the branch HEAD points to, or an empty branch if HEAD is detached.
+func ResolveHead(repoDir string) (string, string, error) {
+	dirs, err := findGitDirs(repoDir)
+	if err != nil {
+		return "", "", err
+	}
+	sha, name, err := dirs.resolve("HEAD")
+	if err != nil {
+		return "", "", err
+	}
+	if !strings.HasPrefix(name, "refs/heads/") {
+		return sha, "", nil
+	}
+	return sha, strings.TrimPrefix(name, "refs/heads/"), nil
+}
@@ -0,0 +1,210 @@
+package source
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	shaA = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	shaB = "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+)
+
+// writeFixture writes a map of relative paths to contents under root.
+func writeFixture(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory for %s: %v", name, err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+}
+
+// TestResolveHead checks HEAD resolution against fixture repositories.
+func TestResolveHead(t *testing.T) {
+	tests := []struct {
+		name       string
+		files      map[string]string
+		checkout   string // directory under the fixture root to resolve from
+		wantSha    string
+		wantBranch string
+		wantErr    bool
+	}{
+		{
+			name: "loose ref",
+			files: map[string]string{
+				"repo/.git/HEAD":            "ref: refs/heads/main\n",
+				"repo/.git/refs/heads/main": shaA + "\n",
+			},
+			checkout:   "repo",
+			wantSha:    shaA,
+			wantBranch: "main",
+		},
+		{
+			name: "packed ref",
+			files: map[string]string{
+				"repo/.git/HEAD": "ref: refs/heads/main\n",
+				"repo/.git/packed-refs": "# pack-refs with: peeled fully-peeled sorted\n" +
+					shaB + " refs/heads/dev\n" +
+					shaA + " refs/heads/main\n" +
+					shaB + " refs/tags/v1.0.0\n" +
+					"^" + shaA + "\n",
+			},
+			checkout:   "repo",
+			wantSha:    shaA,
+			wantBranch: "main",
+		},
+		{
+			name: "loose ref wins over packed ref",
+			files: map[string]string{
+				"repo/.git/HEAD":            "ref: refs/heads/main\n",
+				"repo/.git/refs/heads/main": shaB + "\n",
+				"repo/.git/packed-refs":     shaA + " refs/heads/main\n",
+			},
+			checkout:   "repo",
+			wantSha:    shaB,
+			wantBranch: "main",
+		},
+		{
+			name: "detached head",
+			files: map[string]string{
+				"repo/.git/HEAD": shaA + "\n",
+			},
+			checkout: "repo",
+			wantSha:  shaA,
+		},
+		{
+			name: "symbolic ref chain",
+			files: map[string]string{
+				"repo/.git/HEAD":               "ref: refs/heads/alias\n",
+				"repo/.git/refs/heads/alias":   "ref: refs/heads/release\n",
+				"repo/.git/refs/heads/release": shaB + "\n",
+				"repo/.git/refs/heads/main":    shaA + "\n",
+			},
+			checkout:   "repo",
+			wantSha:    shaB,
+			wantBranch: "release",
+		},
+		{
+			name: "worktree with relative gitdir and commondir",
+			files: map[string]string{
+				"main/.git/HEAD":                   "ref: refs/heads/main\n",
+				"main/.git/refs/heads/main":        shaA + "\n",
+				"main/.git/packed-refs":            shaB + " refs/heads/feature\n",
+				"main/.git/worktrees/wt/HEAD":      "ref: refs/heads/feature\n",
+				"main/.git/worktrees/wt/commondir": "../..\n",
+				"wt/.git":                          "gitdir: ../main/.git/worktrees/wt\n",
+			},
+			checkout:   "wt",
+			wantSha:    shaB,
+			wantBranch: "feature",
+		},
+		{
+			name: "missing ref",
+			files: map[string]string{
+				"repo/.git/HEAD": "ref: refs/heads/main\n",
+			},
+			checkout: "repo",
+			wantErr:  true,
+		},
+		{
+			name: "invalid gitfile",
+			files: map[string]string{
+				"repo/.git": "not a gitfile\n",
+			},
+			checkout: "repo",
+			wantErr:  true,
+		},
+		{
+			name: "symbolic ref loop",
+			files: map[string]string{
+				"repo/.git/HEAD":         "ref: refs/heads/a\n",
+				"repo/.git/refs/heads/a": "ref: refs/heads/b\n",
+				"repo/.git/refs/heads/b": "ref: refs/heads/a\n",
+			},
+			checkout: "repo",
+			wantErr:  true,
+		},
+		{
+			name: "corrupt ref",
+			files: map[string]string{
+				"repo/.git/HEAD":            "ref: refs/heads/main\n",
+				"repo/.git/refs/heads/main": "fake-local-sha\n",
+			},
+			checkout: "repo",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root, err := ioutil.TempDir("", "refs")
+			if err != nil {
+				t.Fatalf("Failed to create temporary directory: %v", err)
+			}
+			defer os.RemoveAll(root)
+			writeFixture(t, root, tt.files)
+
+			sha, branch, err := ResolveHead(filepath.Join(root, tt.checkout))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Expected an error, but got SHA %s", sha)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if sha != tt.wantSha {
+				t.Errorf("Expected SHA %s, got %s", tt.wantSha, sha)
+			}
+			if branch != tt.wantBranch {
+				t.Errorf("Expected branch %q, got %q", tt.wantBranch, branch)
+			}
+		})
+	}
+}
+
+// TestResolveRef checks resolving named refs other than HEAD.
+func TestResolveRef(t *testing.T) {
+	root, err := ioutil.TempDir("", "refs")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(root)
+	writeFixture(t, root, map[string]string{
+		".git/HEAD":            "ref: refs/heads/main\n",
+		".git/refs/heads/main": shaA + "\n",
+		".git/packed-refs":     shaB + " refs/remotes/origin/main\n",
+	})
+
+	tests := []struct {
+		ref     string
+		wantSha string
+		wantErr bool
+	}{
+		{ref: "refs/heads/main", wantSha: shaA},
+		{ref: "refs/remotes/origin/main", wantSha: shaB},
+		{ref: "refs/heads/missing", wantErr: true},
+	}
+	for _, tt := range tests {
+		sha, err := ResolveRef(root, tt.ref)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error, got SHA %s", tt.ref, sha)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: expected no error, got %v", tt.ref, err)
+		} else if sha != tt.wantSha {
+			t.Errorf("%s: expected SHA %s, got %s", tt.ref, tt.wantSha, sha)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package source
+
+import (
+	"context"
+	"fmt"
+)
+
+// Release is a tagged version of the repository that can be deployed.
+type Release struct {
+	Tag     string
+	Sha     string
+	Version Version
+}
+
+// ReleaseCandidate is a tag or published release before its version is checked.
+type ReleaseCandidate struct {
+	Tag string
+	// Sha is the tagged commit, if the listing includes it
+	Sha string
+	// Prerelease is set when the release is flagged as a prerelease
+	Prerelease bool
+}
+
+// TagResolver is implemented by sources whose release listing doesn't include
+// the tagged commit.
+type TagResolver interface {
+	ResolveTag(ctx context.Context, tag string) (string, error)
+}
+
+// LatestRelease picks the newest release of src matching the constraint,
+// looking up its commit if the listing left it out.
+func LatestRelease(ctx context.Context, src Source, constraint string, prerelease bool) (Release, error) {
+	candidates, err := src.ListReleases(ctx)
+	if err != nil {
+		return Release{}, err
+	}
+	best, err := LatestMatching(candidates, constraint, prerelease)
+	if err != nil {
+		return Release{}, err
+	}
+	if best.Sha == "" {
+		resolver, ok := src.(TagResolver)
+		if !ok {
+			return Release{}, fmt.Errorf("release %s has no commit", best.Tag)
+		}
+		if best.Sha, err = resolver.ResolveTag(ctx, best.Tag); err != nil {
+			return Release{}, err
+		}
+	}
+	return best, nil
+}
+
+// LatestMatching picks the highest version among the candidates that matches
+// the constraint. Tags that aren't versions are skipped, and prereleases are
+// only considered when prerelease is true.
+func LatestMatching(candidates []ReleaseCandidate, constraint string, prerelease bool) (Release, error) {
+	c, err := ParseConstraint(constraint)
+	if err != nil {
+		return Release{}, err
+	}
+
+	var best Release
+	found := false
+	for _, candidate := range candidates {
+		v, err := ParseVersion(candidate.Tag)
+		if err != nil {
+			// Not a version tag
+			continue
+		}
+		if (v.Prerelease != "" || candidate.Prerelease) && !prerelease {
+			continue
+		}
+		if !c.Check(v) {
+			continue
+		}
+		if !found || v.Compare(best.Version) > 0 {
+			best = Release{Tag: candidate.Tag, Sha: candidate.Sha, Version: v}
+			found = true
+		}
+	}
+	if !found {
+		return Release{}, fmt.Errorf("no release matches constraint %q", constraint)
+	}
+	return best, nil
+}
@@ -0,0 +1,40 @@
+package source
+
+import (
+	"context"
+	"testing"
+)
+
+// TestLatestMatching_SkipsNonSemver checks that tags which only look like
+// numbers, such as dates or build numbers, don't outrank version tags.
+func TestLatestMatching_SkipsNonSemver(t *testing.T) {
+	candidates := []ReleaseCandidate{
+		{Tag: "v1.4.2", Sha: "sha142"},
+		{Tag: "2024", Sha: "sha2024"},
+		{Tag: "20240101", Sha: "sha20240101"},
+		{Tag: "2024.01.15", Sha: "shadate"},
+		{Tag: "v1.5", Sha: "sha15"},
+	}
+	release, err := LatestMatching(candidates, "", false)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if release.Tag != "v1.4.2" {
+		t.Fatalf("Expected v1.4.2, got %s", release.Tag)
+	}
+}
+
+// TestLatestRelease_NoCommit checks that a release without a commit is an
+// error when the source can't look up its tag.
+func TestLatestRelease_NoCommit(t *testing.T) {
+	src := &MockSource{OverrideRelease: Release{Tag: "v1.0.0"}}
+	if _, err := LatestRelease(context.Background(), src, "", false); err == nil {
+		t.Fatalf("Expected an error for a release without a commit, but got nil")
+	}
+
+	src.OverrideRelease.Sha = "sha100"
+	release, err := LatestRelease(context.Background(), src, "", false)
+	if err != nil || release.Sha != "sha100" {
+		t.Fatalf("Expected v1.0.0 (sha100), got %s (%s) (%v)", release.Tag, release.Sha, err)
+	}
+}
@@ -0,0 +1,40 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// retryAttempts is how many times an operation failing transiently is tried.
+const retryAttempts = 4
+
+// RetryDelay is the wait before the first retry, doubling for each one after.
+// Tests shorten it.
+var RetryDelay = 2 * time.Second
+
+// Retry calls fn until it succeeds, fails with an error that isn't transient,
+// or has been tried retryAttempts times, waiting with exponential backoff and
+// jitter in between. A rate limit is returned at once, as its wait is far
+// longer than any backoff.
+func Retry(ctx context.Context, what string, fn func() error) error {
+	delay := RetryDelay
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		var rateLimited *RateLimitError
+		if err == nil || !IsTransient(err) || errors.As(err, &rateLimited) || attempt == retryAttempts {
+			return err
+		}
+
+		// Wait between half and all of the delay, so clients failing together spread out
+		wait := delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+		Logger(ctx).Printf("%s failed (attempt %d of %d), retrying in %v: %v", what, attempt, retryAttempts, wait.Round(time.Millisecond), err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		delay *= 2
+	}
+}
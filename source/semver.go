@@ -0,0 +1,208 @@
+package source
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a parsed semantic version such as v1.4.2 or 2.0.0-rc.1.
+type Version struct {
+	Major      int
+	Minor      int
+	Patch      int
+	Prerelease string
+}
+
+// ParseVersion parses a semantic version such as a release tag, allowing a
+// leading "v". MAJOR.MINOR.PATCH must all be given without leading zeros, so
+// tags such as 2024 or 2024.01.15 aren't taken for versions. Build metadata
+// is ignored.
+func ParseVersion(s string) (Version, error) {
+	return parseVersion(s, 3)
+}
+
+// parseVersion parses a version of at least minParts components, reading
+// missing minor or patch components as zero (1.4 is 1.4.0).
+func parseVersion(s string, minParts int) (Version, error) {
+	var v Version
+	str := strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.Index(str, "+"); i >= 0 {
+		str = str[:i]
+	}
+	if i := strings.Index(str, "-"); i >= 0 {
+		v.Prerelease = str[i+1:]
+		str = str[:i]
+		if v.Prerelease == "" {
+			return Version{}, fmt.Errorf("invalid version %q: empty prerelease", s)
+		}
+	}
+
+	parts := strings.Split(str, ".")
+	if len(parts) < minParts || len(parts) > 3 {
+		return Version{}, fmt.Errorf("invalid version %q", s)
+	}
+	nums := []*int{&v.Major, &v.Minor, &v.Patch}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 || (len(part) > 1 && part[0] == '0') {
+			return Version{}, fmt.Errorf("invalid version %q", s)
+		}
+		*nums[i] = n
+	}
+	return v, nil
+}
+
+// String formats the version without a leading "v".
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Prerelease != "" {
+		s += "-" + v.Prerelease
+	}
+	return s
+}
+
+// Compare returns -1, 0 or 1 if v is lower than, equal to or higher than o,
+// following semver precedence rules.
+func (v Version) Compare(o Version) int {
+	for _, d := range []int{v.Major - o.Major, v.Minor - o.Minor, v.Patch - o.Patch} {
+		if d < 0 {
+			return -1
+		}
+		if d > 0 {
+			return 1
+		}
+	}
+	return comparePrerelease(v.Prerelease, o.Prerelease)
+}
+
+// comparePrerelease compares two prerelease strings. A version without a
+// prerelease has higher precedence than one with.
+func comparePrerelease(a, b string) int {
+	if a == b {
+		return 0
+	}
+	if a == "" {
+		return 1
+	}
+	if b == "" {
+		return -1
+	}
+
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aErr := strconv.Atoi(as[i])
+		bn, bErr := strconv.Atoi(bs[i])
+		switch {
+		case aErr == nil && bErr == nil:
+			if an != bn {
+				if an < bn {
+					return -1
+				}
+				return 1
+			}
+		case aErr == nil:
+			// Numeric identifiers have lower precedence than alphanumeric ones
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			if c := strings.Compare(as[i], bs[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	switch {
+	case len(as) < len(bs):
+		return -1
+	case len(as) > len(bs):
+		return 1
+	}
+	return 0
+}
+
+// versionBound is a single comparison such as >=1.4.0.
+type versionBound struct {
+	op      string
+	version Version
+}
+
+// Constraint is a set of version bounds that must all hold.
+type Constraint struct {
+	bounds []versionBound
+}
+
+// ParseConstraint parses a version constraint. Supported forms are an exact
+// version (1.4.2), comparisons (>=1.4, <2), tilde ranges (~1.4 allows 1.4.x),
+// caret ranges (^1.4 allows 1.x.x from 1.4.0) and "*" or "" for any version.
+// Multiple bounds are separated by spaces or commas and must all match.
+func ParseConstraint(s string) (Constraint, error) {
+	var c Constraint
+	fields := strings.FieldsFunc(s, func(r rune) bool { return r == ' ' || r == ',' })
+	for _, field := range fields {
+		if field == "*" {
+			continue
+		}
+
+		op := ""
+		for _, prefix := range []string{">=", "<=", "!=", ">", "<", "=", "~", "^"} {
+			if strings.HasPrefix(field, prefix) {
+				op = prefix
+				break
+			}
+		}
+		raw := strings.TrimPrefix(field, op)
+		v, err := parseVersion(raw, 1)
+		if err != nil {
+			return Constraint{}, fmt.Errorf("invalid constraint %q: %v", s, err)
+		}
+		// Number of components given, so ~1 and ~1.4 can widen correctly
+		given := len(strings.Split(strings.TrimPrefix(strings.SplitN(raw, "-", 2)[0], "v"), "."))
+
+		switch op {
+		case "~":
+			upper := Version{Major: v.Major, Minor: v.Minor + 1}
+			if given == 1 {
+				upper = Version{Major: v.Major + 1}
+			}
+			c.bounds = append(c.bounds, versionBound{">=", v}, versionBound{"<", upper})
+		case "^":
+			upper := Version{Major: v.Major + 1}
+			if v.Major == 0 && given > 1 {
+				upper = Version{Minor: v.Minor + 1}
+			}
+			c.bounds = append(c.bounds, versionBound{">=", v}, versionBound{"<", upper})
+		case "":
+			c.bounds = append(c.bounds, versionBound{"=", v})
+		default:
+			c.bounds = append(c.bounds, versionBound{op, v})
+		}
+	}
+	return c, nil
+}
+
+// Check reports whether v satisfies every bound of the constraint.
+func (c Constraint) Check(v Version) bool {
+	for _, b := range c.bounds {
+		cmp := v.Compare(b.version)
+		var ok bool
+		switch b.op {
+		case "=":
+			ok = cmp == 0
+		case "!=":
+			ok = cmp != 0
+		case ">":
+			ok = cmp > 0
+		case ">=":
+			ok = cmp >= 0
+		case "<":
+			ok = cmp < 0
+		case "<=":
+			ok = cmp <= 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,99 @@
+package source
+
+import "testing"
+
+// TestParseVersion checks parsing of version tags.
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "v1.4.2", want: "1.4.2"},
+		{in: "1.4", wantErr: true},
+		{in: "2", wantErr: true},
+		{in: "2024", wantErr: true},
+		{in: "20240101", wantErr: true},
+		{in: "2024.01.15", wantErr: true},
+		{in: "v2.0.0-rc.1", want: "2.0.0-rc.1"},
+		{in: "1.0.0+build.5", want: "1.0.0"},
+		{in: "latest", wantErr: true},
+		{in: "1.2.3.4", wantErr: true},
+		{in: "1.0.0-", wantErr: true},
+	}
+	for _, tt := range tests {
+		v, err := ParseVersion(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("ParseVersion(%q): expected error %v, got %v", tt.in, tt.wantErr, err)
+		}
+		if err == nil && v.String() != tt.want {
+			t.Fatalf("ParseVersion(%q): expected %s, got %s", tt.in, tt.want, v)
+		}
+	}
+}
+
+// TestParseConstraint_Partial checks that constraints may leave out minor
+// and patch components, unlike tags.
+func TestParseConstraint_Partial(t *testing.T) {
+	for _, constraint := range []string{"1", "~1.4", ">=2"} {
+		if _, err := ParseConstraint(constraint); err != nil {
+			t.Fatalf("ParseConstraint(%q): unexpected error %v", constraint, err)
+		}
+	}
+}
+
+// TestVersionCompare checks semver precedence, including prereleases.
+func TestVersionCompare(t *testing.T) {
+	ordered := []string{"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta.2", "1.0.0-beta.11", "1.0.0-rc.1", "1.0.0", "1.0.1", "1.1.0", "2.0.0"}
+	for i := 0; i < len(ordered)-1; i++ {
+		a, _ := ParseVersion(ordered[i])
+		b, _ := ParseVersion(ordered[i+1])
+		if a.Compare(b) != -1 || b.Compare(a) != 1 {
+			t.Fatalf("Expected %s < %s", ordered[i], ordered[i+1])
+		}
+		if a.Compare(a) != 0 {
+			t.Fatalf("Expected %s == %s", ordered[i], ordered[i])
+		}
+	}
+}
+
+// TestConstraintCheck checks which versions each constraint allows.
+func TestConstraintCheck(t *testing.T) {
+	tests := []struct {
+		constraint string
+		allowed    []string
+		rejected   []string
+	}{
+		{constraint: "", allowed: []string{"0.0.1", "9.9.9"}},
+		{constraint: "*", allowed: []string{"1.0.0"}},
+		{constraint: "1.4.2", allowed: []string{"1.4.2"}, rejected: []string{"1.4.3"}},
+		{constraint: "~1.4", allowed: []string{"1.4.0", "1.4.9"}, rejected: []string{"1.3.9", "1.5.0"}},
+		{constraint: "~1", allowed: []string{"1.0.0", "1.9.0"}, rejected: []string{"2.0.0"}},
+		{constraint: "^1.4", allowed: []string{"1.4.0", "1.9.9"}, rejected: []string{"1.3.0", "2.0.0"}},
+		{constraint: "^0.3", allowed: []string{"0.3.5"}, rejected: []string{"0.4.0"}},
+		{constraint: ">=1.2, <2", allowed: []string{"1.2.0", "1.9.9"}, rejected: []string{"1.1.9", "2.0.0"}},
+		{constraint: "!=1.0.1", allowed: []string{"1.0.0"}, rejected: []string{"1.0.1"}},
+	}
+	for _, tt := range tests {
+		c, err := ParseConstraint(tt.constraint)
+		if err != nil {
+			t.Fatalf("ParseConstraint(%q): unexpected error %v", tt.constraint, err)
+		}
+		for _, s := range tt.allowed {
+			v, _ := ParseVersion(s)
+			if !c.Check(v) {
+				t.Fatalf("Expected %q to allow %s", tt.constraint, s)
+			}
+		}
+		for _, s := range tt.rejected {
+			v, _ := ParseVersion(s)
+			if c.Check(v) {
+				t.Fatalf("Expected %q to reject %s", tt.constraint, s)
+			}
+		}
+	}
+
+	if _, err := ParseConstraint("~banana"); err == nil {
+		t.Fatalf("Expected an error for an invalid constraint, but got nil")
+	}
+}
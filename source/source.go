@@ -0,0 +1,18 @@
+package source
+
+import "context"
+
+// Source is an interface that defines the functions interacting with the
+// hosting service of the tracked repository and its local checkout.
+// Each backend (GitHub, GitLab, ...) implements it.
+type Source interface {
+	GetBranchSum(ctx context.Context, branch string) (string, error)
+	GetCurrentSum(branch string) (string, error)
+	ListReleases(ctx context.Context) ([]ReleaseCandidate, error)
+	CheckLastRun(ctx context.Context, sha string, workflows []string) (checks, workflowRuns []CheckResult, err error)
+	CheckDifferences(ctx context.Context, oldSha, newSha string) ([]string, error)
+	ListCommits(ctx context.Context, oldSha, newSha string) ([]string, error)
+	RunGitPull(ctx context.Context, repoDir, branch string) error
+	FastForward(ctx context.Context, repoDir, branch, sha string) error
+	CheckoutTag(ctx context.Context, repoDir, tag string) error
+}